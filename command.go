@@ -0,0 +1,150 @@
+package router
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// routeJSON is the JSON form of a route written by WriteRoutesJSON
+type routeJSON struct {
+	Methods        []string         `json:"methods"`
+	Pattern        string           `json:"pattern"`
+	Host           string           `json:"host,omitempty"`
+	Schemes        []string         `json:"schemes,omitempty"`
+	Headers        []constraintJSON `json:"headers,omitempty"`
+	Queries        []constraintJSON `json:"queries,omitempty"`
+	Name           string           `json:"name,omitempty"`
+	Handler        string           `json:"handler,omitempty"`
+	RedirectPath   string           `json:"redirect_path,omitempty"`
+	RedirectStatus int              `json:"redirect_status,omitempty"`
+}
+
+// constraintJSON is the JSON form of a header or query value required by a route
+type constraintJSON struct {
+	Key     string `json:"key"`
+	Pattern string `json:"pattern"`
+}
+
+// RoutesCommand lists the routes in the router, for use as a command in an app's main function
+// Usage: os.Exit(router.RoutesCommand(r, os.Args[2:], os.Stdout, os.Stderr))
+// Flags are -json to write JSON instead of a table, and -check to report conflicts and invalid routes to errw
+// It returns the exit status - 1 if -check finds problems, 2 for invalid flags, otherwise 0
+func RoutesCommand(r *Router, args []string, w io.Writer, errw io.Writer) int {
+	flags := flag.NewFlagSet("routes", flag.ContinueOnError)
+	flags.SetOutput(errw)
+	asJSON := flags.Bool("json", false, "write routes as JSON")
+	check := flags.Bool("check", false, "exit with status 1 if routes are invalid or conflict")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var err error
+	if *asJSON {
+		err = r.WriteRoutesJSON(w)
+	} else {
+		err = r.WriteRoutes(w)
+	}
+	if err != nil {
+		fmt.Fprintf(errw, "Error writing routes %s\n", err)
+		return 1
+	}
+
+	if !*check {
+		return 0
+	}
+
+	status := 0
+	if err := r.Err(); err != nil {
+		fmt.Fprintln(errw, err)
+		status = 1
+	}
+	for _, c := range r.Validate() {
+		fmt.Fprintln(errw, c)
+		status = 1
+	}
+	return status
+}
+
+// WriteRoutes writes a table of the routes in the router, with their methods, patterns, constraints, names and handlers
+func (r *Router) WriteRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHODS\tPATTERN\tCONSTRAINTS\tNAME\tHANDLER")
+	for _, info := range r.Routes() {
+		j := newRouteJSON(info)
+		handler := j.Handler
+		if j.RedirectStatus != 0 {
+			handler = fmt.Sprintf("redirect %d %s", j.RedirectStatus, j.RedirectPath)
+		}
+		fmt.Fprintf(tw, "%s\t%s%s\t%s\t%s\t%s\n", strings.Join(j.Methods, ","), j.Host, j.Pattern, j.constraints(), j.Name, handler)
+	}
+	return tw.Flush()
+}
+
+// WriteRoutesJSON writes the routes in the router as a JSON array, in the order they were added
+func (r *Router) WriteRoutesJSON(w io.Writer) error {
+	routes := []routeJSON{}
+	for _, info := range r.Routes() {
+		routes = append(routes, newRouteJSON(info))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(routes)
+}
+
+// HandlerName returns the name of the function for handler, or "" if handler is nil
+func HandlerName(handler Handler) string {
+	if handler == nil {
+		return ""
+	}
+	f := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if f == nil {
+		return ""
+	}
+	return f.Name()
+}
+
+// newRouteJSON returns the JSON form of the route described by info
+func newRouteJSON(info RouteInfo) routeJSON {
+	j := routeJSON{
+		Methods:        info.Methods,
+		Pattern:        info.Pattern,
+		Host:           info.Host,
+		Schemes:        info.Schemes,
+		Name:           info.Name,
+		Handler:        HandlerName(info.Handler),
+		RedirectPath:   info.RedirectPath,
+		RedirectStatus: info.RedirectStatus,
+	}
+	for _, v := range info.Headers {
+		j.Headers = append(j.Headers, constraintJSON{Key: v.Key, Pattern: v.Pattern})
+	}
+	for _, v := range info.Queries {
+		j.Queries = append(j.Queries, constraintJSON{Key: v.Key, Pattern: v.Pattern})
+	}
+	return j
+}
+
+// constraints returns the scheme, header and query constraints of the route for the table, or - if there are none
+func (j routeJSON) constraints() string {
+	var parts []string
+	if len(j.Schemes) > 0 {
+		parts = append(parts, "scheme="+strings.Join(j.Schemes, ","))
+	}
+	for _, c := range j.Headers {
+		parts = append(parts, fmt.Sprintf("header:%s=%s", c.Key, c.Pattern))
+	}
+	for _, c := range j.Queries {
+		parts = append(parts, fmt.Sprintf("query:%s=%s", c.Key, c.Pattern))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
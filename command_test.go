@@ -0,0 +1,93 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestWriteRoutes checks the table of routes includes constraints, so routes differing only by constraints differ
+func TestWriteRoutes(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/users", testHandler).Name("users").Header("Accept", "application/vnd.example.v1+json")
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v2+json").Query("page", "{page:int}").Scheme("https")
+	r.AddRedirect("/old", "/new", 301)
+
+	b := &bytes.Buffer{}
+	if err := r.WriteRoutes(b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], "CONSTRAINTS") {
+		t.Fatalf("table wrong:\n%s", b)
+	}
+	tests := []string{
+		"GET,HEAD /users header:Accept=application/vnd.example.v1+json users github.com/fragmenta/router.testHandler",
+		"GET,HEAD /users scheme=https header:Accept=application/vnd.example.v2+json query:page={page:int} github.com/fragmenta/router.testHandler",
+		"GET,HEAD /old - redirect 301 /new",
+	}
+	for i, want := range tests {
+		if got := strings.Join(strings.Fields(lines[i+1]), " "); got != want {
+			t.Errorf("line %d got %q want %q", i+1, got, want)
+		}
+	}
+}
+
+// TestWriteRoutesJSON checks the JSON for routes includes constraints
+func TestWriteRoutesJSON(t *testing.T) {
+	r := newTestRouter(t)
+	b := &bytes.Buffer{}
+	if err := r.WriteRoutesJSON(b); err != nil || strings.TrimSpace(b.String()) != "[]" {
+		t.Errorf("empty routes wrong: %s %v", b, err)
+	}
+
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v2+json").Query("page", "").Scheme("https")
+	b.Reset()
+	if err := r.WriteRoutesJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []routeJSON
+	if err := json.Unmarshal(b.Bytes(), &routes); err != nil {
+		t.Fatalf("JSON invalid: %s", err)
+	}
+	want := routeJSON{
+		Methods: []string{"GET", "HEAD"},
+		Pattern: "/users",
+		Schemes: []string{"https"},
+		Headers: []constraintJSON{{"Accept", "application/vnd.example.v2+json"}},
+		Queries: []constraintJSON{{"page", ""}},
+		Handler: "github.com/fragmenta/router.testHandler",
+	}
+	if len(routes) != 1 || !equalRouteJSON(routes[0], want) {
+		t.Errorf("routes wrong: got %+v want %+v", routes, want)
+	}
+}
+
+// TestRoutesCommand checks the exit status of RoutesCommand
+func TestRoutesCommand(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/users", testHandler)
+
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	if status := RoutesCommand(r, []string{"-check"}, out, errOut); status != 0 || errOut.Len() != 0 {
+		t.Errorf("check of valid routes: status %d %s", status, errOut)
+	}
+	if status := RoutesCommand(r, []string{"-unknown"}, out, errOut); status != 2 {
+		t.Errorf("invalid flag: status %d", status)
+	}
+
+	r.Add("/users", testHandler)
+	errOut.Reset()
+	if status := RoutesCommand(r, []string{"-json", "-check"}, out, errOut); status != 1 || !strings.Contains(errOut.String(), "duplicate") {
+		t.Errorf("check of duplicate routes: status %d %s", status, errOut)
+	}
+}
+
+// equalRouteJSON returns true if a and b describe the same route
+func equalRouteJSON(a, b routeJSON) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,264 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// ConflictKind describes how two routes conflict
+type ConflictKind int
+
+// The kinds of conflict reported by Router.Validate
+const (
+	// ConflictDuplicate means a route has the same pattern as an earlier route
+	ConflictDuplicate ConflictKind = iota
+
+	// ConflictShadowed means every request a route matches is matched first by an earlier route, so it is never used
+	ConflictShadowed
+
+	// ConflictOverlap means some requests a route matches are matched first by an earlier route
+	ConflictOverlap
+)
+
+// String returns a description of the kind of conflict
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictDuplicate:
+		return "duplicate"
+	case ConflictShadowed:
+		return "shadowed"
+	case ConflictOverlap:
+		return "overlap"
+	}
+	return "unknown"
+}
+
+// Conflict describes a route which conflicts with a route added before it
+type Conflict struct {
+	// The kind of conflict
+	Kind ConflictKind
+
+	// The route added later, which loses to Earlier
+	Route *Route
+
+	// The route added earlier, which takes priority over Route
+	Earlier *Route
+
+	// The methods for which Earlier takes priority over Route
+	Methods []string
+}
+
+// Error returns a description of the conflict, so that conflicts may be returned as errors
+func (c *Conflict) Error() string {
+	return fmt.Sprintf("Route conflict (%s): %s conflicts with earlier route %s for %s", c.Kind, c.Route, c.Earlier, c.Methods)
+}
+
+// Validate checks every route against the routes added before it, and returns any conflicts
+// Duplicates are found exactly, shadowed routes are only reported where this can be proved from the
+// patterns, and overlaps between params are found by testing sample paths, so some may be missed
+func (r *Router) Validate() []*Conflict {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var conflicts []*Conflict
+	for i, route := range r.routes {
+		for _, earlier := range r.routes[:i] {
+			if c := findConflict(earlier, route); c != nil {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+	return conflicts
+}
+
+// findConflict returns the conflict between route and an earlier route, or nil if they do not conflict
+func findConflict(earlier, route *Route) *Conflict {
+	// Routes with different host, scheme, header or query constraints are assumed not to conflict
+	constraints := earlier.constraints()
+	if constraints != "" && constraints != route.constraints() {
+		return nil
+	}
+
+	var methods []string
+	for _, m := range route.methods {
+		if earlier.MatchMethod(m) {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	c := &Conflict{Route: route, Earlier: earlier, Methods: methods}
+	switch {
+	case earlier.Pattern == route.Pattern && constraints == route.constraints():
+		c.Kind = ConflictDuplicate
+	case covers(earlier, route) && len(methods) == len(route.methods):
+		c.Kind = ConflictShadowed
+	case covers(earlier, route):
+		c.Kind = ConflictOverlap
+	case covers(route, earlier):
+		// A more specific route before a general one is the expected order
+		return nil
+	case earlier.MatchPath(route.samplePath()) || route.MatchPath(earlier.samplePath()):
+		c.Kind = ConflictOverlap
+	default:
+		return nil
+	}
+	return c
+}
+
+// routeToken is a part of a route pattern, either literal text or a param
+type routeToken struct {
+	literal string
+	param   *routeParam
+}
+
+// tokens splits the route pattern into literal text and params
+func (r *Route) tokens() []routeToken {
+	var tokens []routeToken
+	idxs, err := r.findBraces(r.Pattern)
+	if err != nil {
+		return nil
+	}
+
+	end := 0
+	for i := 0; i < len(idxs); i += 2 {
+		if idxs[i] > end {
+			tokens = append(tokens, routeToken{literal: r.Pattern[end:idxs[i]]})
+		}
+		param, err := parseParam(r.Pattern[idxs[i]+1 : idxs[i+1]-1])
+		if err != nil {
+			return nil
+		}
+		tokens = append(tokens, routeToken{param: &param})
+		end = idxs[i+1]
+	}
+	if end < len(r.Pattern) {
+		tokens = append(tokens, routeToken{literal: r.Pattern[end:]})
+	}
+	return tokens
+}
+
+// samplePath returns a path matched by this route, with a sample value for each param
+func (r *Route) samplePath() string {
+	var sample string
+	for _, t := range r.tokens() {
+		if t.param == nil {
+			sample += t.literal
+			continue
+		}
+		re, err := syntax.Parse(t.param.pattern, syntax.Perl)
+		if err == nil {
+			sample += sampleString(re.Simplify())
+		}
+	}
+	return sample
+}
+
+// covers returns true if every path matched by route is also matched by earlier
+func covers(earlier, route *Route) bool {
+	// Plain string routes only cover themselves
+	if earlier.Regexp == nil {
+		return route.Regexp == nil && earlier.Pattern == route.Pattern
+	}
+	if route.Regexp == nil {
+		return earlier.MatchPath(route.Pattern)
+	}
+
+	a, b := earlier.tokens(), route.tokens()
+	if a == nil || b == nil {
+		return false
+	}
+
+	// Regexps are not anchored at the end, so earlier covers route if its tokens cover a prefix of those of route
+	for i, t := range a {
+		if i >= len(b) {
+			return false
+		}
+		u := b[i]
+
+		if t.param == nil {
+			if u.param != nil {
+				return false
+			}
+			if i == len(a)-1 || (i == len(a)-2 && matchesAll(a[i+1].param)) {
+				return strings.HasPrefix(u.literal, t.literal)
+			}
+			if t.literal != u.literal {
+				return false
+			}
+			continue
+		}
+
+		if u.param == nil {
+			return false
+		}
+		if t.param.catchAll {
+			return true
+		}
+		if u.param.catchAll || !paramCovers(t.param, u.param) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAll returns true if param matches any text, including slashes, when it ends a pattern
+func matchesAll(param *routeParam) bool {
+	return param != nil && (param.catchAll || param.pattern == ".*")
+}
+
+// paramCovers returns true if every value matched by param b is matched by param a
+func paramCovers(a, b *routeParam) bool {
+	if a.pattern == b.pattern {
+		return true
+	}
+
+	re, err := syntax.Parse(b.pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	empty := regexp.MustCompile("^(?:" + b.pattern + ")$").MatchString("")
+
+	switch a.pattern {
+	case ".*":
+		return true
+	case ".+":
+		return !empty
+	case defaultParamPattern:
+		return !empty && !matchesSlash(re)
+	}
+
+	return false
+}
+
+// sampleString returns a short string matched by the parsed regexp
+func sampleString(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCharClass:
+		if len(re.Rune) > 0 {
+			return string(re.Rune[0])
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return "a"
+	case syntax.OpCapture, syntax.OpPlus:
+		return sampleString(re.Sub[0])
+	case syntax.OpRepeat:
+		return strings.Repeat(sampleString(re.Sub[0]), re.Min)
+	case syntax.OpAlternate:
+		return sampleString(re.Sub[0])
+	case syntax.OpConcat:
+		var s string
+		for _, sub := range re.Sub {
+			s += sampleString(sub)
+		}
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"testing"
+)
+
+// TestValidate checks the conflicts reported between two routes
+func TestValidate(t *testing.T) {
+	get := func(r *Route) {}
+	post := func(r *Route) { r.Post() }
+	getPost := func(r *Route) { r.Methods("GET", "POST") }
+	host := func(r *Route) { r.Host("a.example.com") }
+	otherHost := func(r *Route) { r.Host("b.example.com") }
+
+	// none is used where no conflict is expected
+	const none = ConflictKind(-1)
+
+	tests := []struct {
+		name         string
+		earlier      string
+		earlierSetup func(*Route)
+		route        string
+		routeSetup   func(*Route)
+		want         ConflictKind
+	}{
+		{"duplicate", "/users/{id:int}", get, "/users/{id:int}", get, ConflictDuplicate},
+		{"duplicate static", "/users", get, "/users", get, ConflictDuplicate},
+		{"duplicate other method", "/users/{id:int}", get, "/users/{id:int}", post, none},
+		{"duplicate some methods", "/users/{id:int}", get, "/users/{id:int}", getPost, ConflictDuplicate},
+
+		// Route regexps are not anchored at the end, so routes are shadowed by routes matching a prefix
+		{"unanchored param", "/users/{id:int}", get, "/users/{id:int}/edit", get, ConflictShadowed},
+		{"unanchored trailing literal", "/docs/{id}", get, "/docs/{id}.json", get, ConflictShadowed},
+		{"unanchored literal prefix", "/users/{id}/ed", get, "/users/{id}/edit", get, ConflictShadowed},
+		{"static not unanchored", "/users", get, "/users/create", get, none},
+		{"param covers type", "/users/{name}", get, "/users/{id:int}", get, ConflictShadowed},
+		{"shadowed some methods", "/users/{name}", get, "/users/{id:int}", getPost, ConflictOverlap},
+		{"specific first", "/users/create", get, "/users/{name}", get, none},
+		{"specific param first", "/users/{id:int}", get, "/users/{name}", get, none},
+
+		// Only the general patterns .*, .+ and [^/]+ are proved to cover other params,
+		// so narrower regexps which happen to cover another are reported as overlaps
+		{"narrower regexp", "/a/{x:[a-z]+}", get, "/a/{y:[a-c]+}", get, ConflictOverlap},
+		{"overlapping regexps", "/a/{x:[a-z0-9]+}", get, "/a/{y:[0-9]+}x", get, ConflictOverlap},
+		{"disjoint regexps", "/pages/{id:[0-9]+}", get, "/pages/{name:[a-z]+}", get, none},
+
+		{"catch-all", "/docs/{path...}", get, "/docs/a/{x}", get, ConflictShadowed},
+		{"catch-all bare path", "/docs/{path...}", get, "/docs", get, ConflictShadowed},
+		{"catch-all some methods", "/docs/{path...}", get, "/docs/a/{x}", getPost, ConflictOverlap},
+		{"catch-all other prefix", "/docs/{path...}", get, "/docsx", get, none},
+		{"catch-all after specific", "/docs/a", get, "/docs/{path...}", get, none},
+		{"regexp catch-all", "/{all:.*}", get, "/users/{id:int}", get, ConflictShadowed},
+
+		// Routes with different constraints are assumed not to conflict, except that routes without constraints match every host
+		{"host duplicate", "/users", host, "/users", host, ConflictDuplicate},
+		{"host earlier", "/users", host, "/users", get, none},
+		{"host later", "/users", get, "/users", host, ConflictShadowed},
+		{"other hosts", "/users", host, "/users", otherHost, none},
+	}
+
+	for _, tt := range tests {
+		r := newTestRouter(t)
+		earlier := r.Add(tt.earlier, testHandler)
+		tt.earlierSetup(earlier)
+		route := r.Add(tt.route, testHandler)
+		tt.routeSetup(route)
+
+		conflicts := r.Validate()
+		if tt.want == none {
+			if len(conflicts) != 0 {
+				t.Errorf("%s: got %s want no conflict", tt.name, conflicts[0])
+			}
+			continue
+		}
+
+		if len(conflicts) != 1 {
+			t.Errorf("%s: got %d conflicts want %s", tt.name, len(conflicts), tt.want)
+			continue
+		}
+		c := conflicts[0]
+		if c.Kind != tt.want || c.Route != route || c.Earlier != earlier {
+			t.Errorf("%s: got %s want %s", tt.name, c, tt.want)
+		}
+	}
+}
+
+// TestValidateMethods checks conflicts list the methods where the earlier route takes priority
+func TestValidateMethods(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/docs/{path...}", testHandler).Methods("GET", "POST")
+	r.Add("/docs/a/{x}", testHandler).Methods("POST", "PUT")
+
+	conflicts := r.Validate()
+	if len(conflicts) != 1 || len(conflicts[0].Methods) != 1 || conflicts[0].Methods[0] != "POST" {
+		t.Errorf("conflict methods wrong: %v", conflicts)
+	}
+}
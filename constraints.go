@@ -0,0 +1,296 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// hostParamPattern is the regexp used for host params without a pattern, e.g. {tenant}, matching a single label
+const hostParamPattern = "[^.]+"
+
+// valueParamPattern is the regexp used for header and query params without a pattern, matching any value
+const valueParamPattern = ".+"
+
+// valueMatcher matches a host, header or query value against a pattern which may contain params
+type valueMatcher struct {
+	// The header or query key, empty for hosts
+	key string
+
+	// The pattern, in the same form as route patterns, empty to match any value
+	pattern string
+
+	// The regexp compiled from pattern, matching the whole value
+	regexp *regexp.Regexp
+
+	// Params taken from the pattern
+	params []routeParam
+}
+
+// newValueMatcher compiles pattern to match whole values, using defaultPattern for params with no pattern
+func newValueMatcher(key, pattern, defaultPattern string, ignoreCase bool) (*valueMatcher, error) {
+	m := &valueMatcher{key: key, pattern: pattern}
+	if pattern == "" {
+		return m, nil
+	}
+
+	idxs, err := (&Route{}).findBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := bytes.NewBufferString("^")
+	if ignoreCase {
+		expr = bytes.NewBufferString("(?i)^")
+	}
+
+	end := 0
+	for i := 0; i < len(idxs); i += 2 {
+		raw := pattern[end:idxs[i]]
+		end = idxs[i+1]
+
+		spec := pattern[idxs[i]+1 : end-1]
+		param, err := parseParam(spec)
+		if err != nil {
+			return nil, err
+		}
+		if param.catchAll {
+			return nil, fmt.Errorf("catch-all param %s is only allowed in paths", param.name)
+		}
+
+		// Params with no pattern use the default for this kind of value
+		if !strings.Contains(spec, ":") {
+			param.pattern = defaultPattern
+		}
+
+		m.params = append(m.params, param)
+		fmt.Fprintf(expr, "%s(%s)", regexp.QuoteMeta(raw), param.pattern)
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[end:]))
+	expr.WriteString("$")
+
+	m.regexp, err = regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// match returns true if value matches our pattern, or we have no pattern
+func (m *valueMatcher) match(value string) bool {
+	return m.regexp == nil || m.regexp.MatchString(value)
+}
+
+// parse adds the params captured from value to params
+func (m *valueMatcher) parse(value string, params map[string]string) {
+	if m.regexp == nil {
+		return
+	}
+	matches := m.regexp.FindStringSubmatch(value)
+	for i, param := range m.params {
+		if len(matches) > i+1 {
+			params[param.name] = matches[i+1]
+		}
+	}
+}
+
+// matchHeader returns the value of our header which matches, and true if there is one
+// Accept headers are lists of media ranges, each is matched without its params, and ranges with q=0 are ignored
+// For other headers only the first value is matched
+func (m *valueMatcher) matchHeader(header http.Header) (string, bool) {
+	values, ok := header[m.key]
+	if !ok {
+		return "", false
+	}
+	if m.key != "Accept" {
+		return values[0], m.match(values[0])
+	}
+
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			mediaType, q := parseMediaRange(part)
+			if q > 0 && m.match(mediaType) {
+				return mediaType, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Host restricts this route to requests for hosts matching pattern, which may contain params like the path
+// Usage: r.Add("/", handler).Host("{tenant:[a-z]+}.example.com")
+// Host params are added to the request params, and any port on the request host is ignored
+func (r *Route) Host(pattern string) *Route {
+	m, err := newValueMatcher("", pattern, hostParamPattern, true)
+	if err != nil {
+		r.setErr(fmt.Errorf("Route error: invalid host %s:%s", pattern, err))
+		m = nil
+	}
+	r.host = pattern
+	r.hostMatcher = m
+	return r
+}
+
+// Header restricts this route to requests with a header value matching pattern, which may contain params
+// Usage: r.Add("/users", handler).Header("Accept", "application/vnd.example.v{version:int}+json")
+// An empty pattern only requires the header to be present, for Accept the pattern may match any media type listed
+func (r *Route) Header(key, pattern string) *Route {
+	m, err := newValueMatcher(http.CanonicalHeaderKey(key), pattern, valueParamPattern, false)
+	if err != nil {
+		r.setErr(fmt.Errorf("Route error: invalid header %s:%s", key, err))
+		return r
+	}
+	r.headers = append(r.headers, m)
+	return r
+}
+
+// Query restricts this route to requests with a query value matching pattern, which may contain params
+// Usage: r.Add("/search", handler).Query("page", "{page:int}")
+// An empty pattern only requires the query key to be present
+func (r *Route) Query(key, pattern string) *Route {
+	m, err := newValueMatcher(key, pattern, valueParamPattern, false)
+	if err != nil {
+		r.setErr(fmt.Errorf("Route error: invalid query %s:%s", key, err))
+		return r
+	}
+	r.queries = append(r.queries, m)
+	return r
+}
+
+// Scheme restricts this route to requests using one of the schemes given, e.g. Scheme("https")
+// The scheme is https for TLS connections, X-Forwarded-Proto is only used for requests from Router.TrustedProxies
+func (r *Route) Scheme(schemes ...string) *Route {
+	r.schemes = nil
+	for _, s := range schemes {
+		r.schemes = append(r.schemes, strings.ToLower(s))
+	}
+	return r
+}
+
+// MatchHost returns true if this route has no host constraint, or the host matches it
+func (r *Route) MatchHost(host string) bool {
+	if r.host == "" {
+		return true
+	}
+	return r.hostMatcher != nil && r.hostMatcher.match(stripPort(host))
+}
+
+// MatchRequest returns true if the request meets the host, scheme, header and query constraints of this route
+// The path and method are checked separately with MatchPath and MatchMethod, and routes with invalid constraints never match
+// Forwarded headers are not trusted, so the scheme is https only for TLS connections
+func (r *Route) MatchRequest(request *http.Request) bool {
+	return r.matchRequest(request, requestScheme(request, false))
+}
+
+// matchRequest returns true if the request meets the constraints of this route, given the scheme used for the request
+func (r *Route) matchRequest(request *http.Request, scheme string) bool {
+	if r.err != nil || !r.MatchHost(request.Host) {
+		return false
+	}
+
+	if len(r.schemes) > 0 && !containsString(r.schemes, scheme) {
+		return false
+	}
+
+	for _, m := range r.headers {
+		if _, ok := m.matchHeader(request.Header); !ok {
+			return false
+		}
+	}
+
+	if len(r.queries) > 0 {
+		query := request.URL.Query()
+		for _, m := range r.queries {
+			values, ok := query[m.key]
+			if !ok || !m.match(values[0]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ParseHost reads our host params from the given host
+func (r *Route) ParseHost(host string) map[string]string {
+	params := make(map[string]string)
+	if r == nil || r.hostMatcher == nil {
+		return params
+	}
+	r.hostMatcher.parse(stripPort(host), params)
+	return params
+}
+
+// ParseRequest reads our host, header and query params from the request
+func (r *Route) ParseRequest(request *http.Request) map[string]string {
+	params := r.ParseHost(request.Host)
+	if r == nil {
+		return params
+	}
+
+	for _, m := range r.headers {
+		if value, ok := m.matchHeader(request.Header); ok {
+			m.parse(value, params)
+		}
+	}
+
+	if len(r.queries) > 0 {
+		query := request.URL.Query()
+		for _, m := range r.queries {
+			m.parse(query.Get(m.key), params)
+		}
+	}
+
+	return params
+}
+
+// constraints returns a description of the host, scheme, header and query constraints of this route
+func (r *Route) constraints() string {
+	var parts []string
+	if r.host != "" {
+		parts = append(parts, "host="+r.host)
+	}
+	if len(r.schemes) > 0 {
+		parts = append(parts, "scheme="+strings.Join(r.schemes, ","))
+	}
+	for _, m := range r.headers {
+		parts = append(parts, fmt.Sprintf("header:%s=%s", m.key, m.pattern))
+	}
+	for _, m := range r.queries {
+		parts = append(parts, fmt.Sprintf("query:%s=%s", m.key, m.pattern))
+	}
+	return strings.Join(parts, " ")
+}
+
+// setErr records the first error from setting up this route
+func (r *Route) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// requestScheme returns the scheme used for the request
+// X-Forwarded-Proto is only used if the request is from a trusted proxy, and then only the first value, set by the first proxy
+func requestScheme(request *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := strings.Split(request.Header.Get("X-Forwarded-Proto"), ",")[0]
+		if scheme := strings.TrimSpace(forwarded); scheme != "" {
+			return strings.ToLower(scheme)
+		}
+	}
+	if request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// stripPort returns the host without any port
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
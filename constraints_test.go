@@ -0,0 +1,164 @@
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestScheme checks scheme constraints only trust X-Forwarded-Proto from trusted proxies
+func TestScheme(t *testing.T) {
+	trusted := func(request *http.Request) bool {
+		return strings.HasPrefix(request.RemoteAddr, "10.0.0.1:")
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		forwarded  string
+		want       int
+	}{
+		{"http", "192.0.2.1:1234", false, "", http.StatusNotFound},
+		{"https", "192.0.2.1:1234", true, "", http.StatusOK},
+		{"untrusted forwarded", "192.0.2.1:1234", false, "https", http.StatusNotFound},
+		{"trusted forwarded", "10.0.0.1:1234", false, "https", http.StatusOK},
+		{"trusted forwarded http", "10.0.0.1:1234", true, "http", http.StatusNotFound},
+		{"trusted forwarded list", "10.0.0.1:1234", false, "HTTPS, http", http.StatusOK},
+		{"trusted forwarded list http first", "10.0.0.1:1234", false, "http, https", http.StatusNotFound},
+		{"trusted no header", "10.0.0.1:1234", false, "", http.StatusNotFound},
+	}
+
+	r := newTestRouter(t, WithTrustedProxies(trusted), WithFileHandler(func(c Context) error {
+		return NotFoundError(nil)
+	}))
+	r.Add("/secure", testHandler).Scheme("https")
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		request.RemoteAddr = tt.remoteAddr
+		if tt.tls {
+			request.TLS = &tls.ConnectionState{}
+		}
+		if tt.forwarded != "" {
+			request.Header.Set("X-Forwarded-Proto", tt.forwarded)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+// TestMatchRequestScheme checks Route.MatchRequest never trusts X-Forwarded-Proto
+func TestMatchRequestScheme(t *testing.T) {
+	route, err := NewRoute("/secure", testHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.Scheme("https")
+
+	request := httptest.NewRequest(http.MethodGet, "https://example.com/secure", nil)
+	request.TLS = nil
+	request.Header.Set("X-Forwarded-Proto", "https")
+	if route.MatchRequest(request) {
+		t.Errorf("MatchRequest trusted X-Forwarded-Proto or the request url")
+	}
+
+	request.TLS = &tls.ConnectionState{}
+	if !route.MatchRequest(request) {
+		t.Errorf("MatchRequest failed for a TLS request")
+	}
+}
+
+// TestHeaderAccept checks Accept header constraints match any media type listed
+func TestHeaderAccept(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []string
+		want   string
+	}{
+		{"single", []string{"application/vnd.example.v2+json"}, "2"},
+		{"list", []string{"application/vnd.example.v2+json, application/json;q=0.5"}, "2"},
+		{"later in list", []string{"text/html, application/vnd.example.v3+json;q=0.9"}, "3"},
+		{"params", []string{"application/vnd.example.v4+json; charset=utf-8"}, "4"},
+		{"several headers", []string{"text/html", "application/vnd.example.v5+json"}, "5"},
+		{"not acceptable", []string{"application/vnd.example.v2+json;q=0, application/json"}, ""},
+		{"other type", []string{"application/json"}, ""},
+		{"missing", nil, ""},
+	}
+
+	r := newTestRouter(t, WithFileHandler(func(c Context) error {
+		return NotFoundError(nil)
+	}))
+	r.Add("/users", func(c Context) error {
+		params, err := c.Params()
+		if err != nil {
+			return err
+		}
+		_, err = c.Writer().Write([]byte(params.Get("version")))
+		return err
+	}).Header("Accept", "application/vnd.example.v{version:int}+json")
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/users", nil)
+		for _, v := range tt.accept {
+			request.Header.Add("Accept", v)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+		if tt.want == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: got %d want %d", tt.name, w.Code, http.StatusNotFound)
+			}
+		} else if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s: got %d %q want version %s", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+// TestHeader checks other headers match their first value
+func TestHeader(t *testing.T) {
+	route, err := NewRoute("/users", testHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.Header("X-Version", "v{version:int}").Header("X-Key", "")
+
+	request := httptest.NewRequest(http.MethodGet, "/users", nil)
+	request.Header.Set("X-Key", "")
+	request.Header.Add("X-Version", "v2")
+	request.Header.Add("X-Version", "other")
+	if !route.MatchRequest(request) || route.ParseRequest(request)["version"] != "2" {
+		t.Errorf("header not matched")
+	}
+
+	request.Header.Del("X-Key")
+	if route.MatchRequest(request) {
+		t.Errorf("missing header matched")
+	}
+}
+
+// TestQueryParams checks query constraint params are not added twice to the request params
+func TestQueryParams(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/search", func(c Context) error {
+		params, err := c.Params()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.Writer(), "%v %v %v", params["page"], params["field"], params.GetInts("page"))
+		return err
+	}).Query("page", "{page:int}").Query("sort", "{field}-{dir}")
+
+	w := serve(r, http.MethodGet, "/search?page=2&sort=name-asc&field=id")
+	if w.Body.String() != "[2] [id name] [2]" {
+		t.Errorf("params wrong: %s", w.Body.String())
+	}
+}
@@ -1,9 +1,10 @@
 package router
 
 import (
+	"context"
 	"mime/multipart"
 	"net/http"
-	"path"
+	"time"
 )
 
 // Context is a request context wrapping a response writer and the request details
@@ -14,9 +15,24 @@ type Context interface {
 	// Request returns the http.Request embedded in this context
 	Request() *http.Request
 
+	// Context returns the request context.Context, with data stored by Set available as values
+	Context() context.Context
+
+	// SetContext replaces the request context.Context, updating the request
+	SetContext(ctx context.Context)
+
 	// Writer returns the http.ResponseWriter embedded in this context
 	Writer() http.ResponseWriter
 
+	// Status returns the response status written so far, 200 if none has been written
+	Status() int
+
+	// BytesWritten returns the number of bytes of response body written so far
+	BytesWritten() int64
+
+	// TimeToFirstByte returns the time from the start of the request until the response was first written
+	TimeToFirstByte() time.Duration
+
 	// Request returns the cleaned path for this request
 	Path() string
 
@@ -38,13 +54,16 @@ type Context interface {
 	// ParamInt returns an int64 key from the request params
 	ParamInt(key string) int64
 
+	// ParamValue returns a typed value for route params with a registered type, or the string value of other params
+	ParamValue(key string) (interface{}, error)
+
 	// ParamFiles parses the request as multipart, and then returns the file parts for this key
 	ParamFiles(key string) ([]*multipart.FileHeader, error)
 
 	// Store arbitrary data for this request
 	Set(key string, data interface{})
 
-	// Retreive arbitrary data for this request
+	// Retreive arbitrary data for this request, falling back to request context values
 	Get(key string) interface{}
 
 	// Return the rendering context (our data)
@@ -60,8 +79,8 @@ type Context interface {
 // ConcreteContext is the request context, including a writer, the current request etc
 type ConcreteContext struct {
 
-	// The current response writer
-	writer http.ResponseWriter
+	// The current response writer, recording the status and bytes written
+	writer *recordingWriter
 
 	// The current request
 	request *http.Request
@@ -87,11 +106,39 @@ func (c *ConcreteContext) Request() *http.Request {
 	return c.request
 }
 
+// Context returns the context.Context for the request, which is cancelled if the client disconnects
+// Data stored with Set is available from the returned context as values with string keys
+func (c *ConcreteContext) Context() context.Context {
+	return dataContext{Context: c.request.Context(), data: c.data}
+}
+
+// SetContext replaces the request context, so that later filters and handlers use ctx
+// Usage: c.SetContext(context.WithValue(c.Context(), key, value))
+func (c *ConcreteContext) SetContext(ctx context.Context) {
+	c.request = c.request.WithContext(ctx)
+}
+
 // Writer returns the http.ResponseWriter for responding to the request
 func (c *ConcreteContext) Writer() http.ResponseWriter {
 	return c.writer
 }
 
+// Status returns the response status written so far, 200 if none has been written
+func (c *ConcreteContext) Status() int {
+	return c.writer.Status()
+}
+
+// BytesWritten returns the number of bytes of response body written so far
+func (c *ConcreteContext) BytesWritten() int64 {
+	return c.writer.bytes
+}
+
+// TimeToFirstByte returns the time from the start of the request until the response was first written
+// It returns 0 if nothing has been written yet
+func (c *ConcreteContext) TimeToFirstByte() time.Duration {
+	return c.writer.firstByte
+}
+
 // Route returns the route handling this request
 func (c *ConcreteContext) Route() *Route {
 	return c.route
@@ -151,6 +198,14 @@ func (c *ConcreteContext) Params() (Params, error) {
 		params.Add(k, v)
 	}
 
+	// And any params from the route host, headers and query, unless they are already in the form values
+	// e.g. Query("page", "{page:int}") captures the query value page again
+	for k, v := range c.route.ParseRequest(c.request) {
+		if !containsString(params[k], v) {
+			params.Add(k, v)
+		}
+	}
+
 	// Return entire params
 	return params, nil
 }
@@ -180,6 +235,22 @@ func (c *ConcreteContext) ParamInt(key string) int64 {
 	return params.GetInt(key)
 }
 
+// ParamValue retreives a single route param as a typed value if it uses a registered type such as {id:int}
+// Other params are returned as strings, as from Param
+func (c *ConcreteContext) ParamValue(key string) (interface{}, error) {
+	if c.route.ParamType(key) != nil {
+		values, err := c.route.ParseValues(c.path)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := values[key]; ok {
+			return value, nil
+		}
+	}
+
+	return c.Param(key), nil
+}
+
 // ParamFiles parses the request as multipart, and then returns the file parts for this key
 // NB it calls ParseMultipartForm prior to reading the parts
 func (c *ConcreteContext) ParamFiles(key string) ([]*multipart.FileHeader, error) {
@@ -214,8 +285,12 @@ func (c *ConcreteContext) Set(key string, data interface{}) {
 }
 
 // Get retreives arbitrary data for this request
+// If no data was set for key, the value from the request context is returned
 func (c *ConcreteContext) Get(key string) interface{} {
-	return c.data[key]
+	if data, ok := c.data[key]; ok {
+		return data
+	}
+	return c.request.Context().Value(key)
 }
 
 // RenderContext returns a context for rendering the view
@@ -223,6 +298,22 @@ func (c *ConcreteContext) RenderContext() map[string]interface{} {
 	return c.data
 }
 
+// dataContext wraps a context.Context so that the data stored for a request is available as values
+type dataContext struct {
+	context.Context
+	data map[string]interface{}
+}
+
+// Value returns the data stored for string keys, or the value for key from the wrapped context
+func (d dataContext) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if data, ok := d.data[k]; ok {
+			return data
+		}
+	}
+	return d.Context.Value(key)
+}
+
 // parseRequest parses our params from the request form (if any)
 func (c *ConcreteContext) parseRequest() error {
 
@@ -246,15 +337,10 @@ func (c *ConcreteContext) parseRequest() error {
 // this should also be used in the Handle function and tests FIXME
 func NewContext(writer http.ResponseWriter, request *http.Request, route *Route, config Config, logger Logger) Context {
 	// Clean the path and store on context
-	canonicalPath := path.Clean(request.URL.Path)
-	if len(canonicalPath) == 0 {
-		canonicalPath = "/"
-	} else if canonicalPath[0] != '/' {
-		canonicalPath = "/" + canonicalPath
-	}
+	canonicalPath := cleanPath(request.URL.Path)
 
 	return &ConcreteContext{
-		writer:  writer,
+		writer:  newRecordingWriter(writer, time.Now()),
 		request: request,
 		path:    canonicalPath,
 		logger:  logger,
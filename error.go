@@ -1,12 +1,39 @@
 package router
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Sentinel errors for common statuses, for use with errors.Is, or to return from handlers
+// They cannot be modified, use a constructor like NotFoundError for an error with headers or extensions
+// Usage: if errors.Is(err, router.ErrNotFound) { ... }
+var (
+	ErrBadRequest       error = &statusSentinel{http.StatusBadRequest, "Bad Request", "Sorry, there was an error processing your request, please check your data."}
+	ErrNotAuthorized    error = &statusSentinel{http.StatusUnauthorized, "Not Allowed", "Sorry, I can't let you do that."}
+	ErrNotFound         error = &statusSentinel{http.StatusNotFound, "Not Found", "Sorry, the page you're looking for couldn't be found."}
+	ErrMethodNotAllowed error = &statusSentinel{http.StatusMethodNotAllowed, "Method Not Allowed", "Sorry, this page doesn't accept that kind of request."}
+	ErrInternal         error = &statusSentinel{http.StatusInternalServerError, "Server Error", "Sorry, something went wrong, please let us know."}
+)
+
+// statusSentinel is the type of the sentinel errors, it stores the status and display title/msg for a StatusError
+type statusSentinel struct {
+	status  int
+	title   string
+	message string
+}
+
+// Error returns the status and title of the sentinel
+func (s *statusSentinel) Error() string {
+	return fmt.Sprintf("Status %d : %s", s.status, s.title)
+}
+
 // StatusError wraps a std error and stores more information (status code, display title/msg and caller info)
 type StatusError struct {
 	Err     error
@@ -15,6 +42,16 @@ type StatusError struct {
 	Message string
 	File    string
 	Line    int
+
+	// The value passed to panic and the stack trace, for errors recovered from panics
+	Panic interface{}
+	Stack []byte
+
+	// Extra members for the problem details rendered when the error is sent as JSON
+	Extensions map[string]interface{}
+
+	// Headers set on the response by the default error handlers, e.g. Retry-After
+	Headers http.Header
 }
 
 // Error returns the underling error string - it should not be shown in production
@@ -27,13 +64,44 @@ func (e *StatusError) String() string {
 	return fmt.Sprintf("Status %d at %s : %s %s %s", e.Status, e.FileLine(), e.Title, e.Message, e.Err)
 }
 
+// Unwrap returns the underlying error, so that errors.Is and errors.As can inspect it
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is a sentinel error like ErrNotFound with the same status as this error
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*statusSentinel)
+	return ok && t.status == e.Status
+}
+
 // FileLine returns file name and line of error
 func (e *StatusError) FileLine() string {
 	parts := strings.Split(e.File, "/")
-	f := strings.Join(parts[len(parts)-4:len(parts)], "/")
+	if len(parts) > 4 {
+		parts = parts[len(parts)-4:]
+	}
+	f := strings.Join(parts, "/")
 	return fmt.Sprintf("%s:%d", f, e.Line)
 }
 
+// WithHeader adds a header to be set on the response when this error is rendered, and returns the error
+// Usage: return router.ServiceUnavailableError(err).WithHeader("Retry-After", "120")
+func (e *StatusError) WithHeader(key, value string) *StatusError {
+	if e.Headers == nil {
+		e.Headers = make(http.Header)
+	}
+	e.Headers.Add(key, value)
+	return e
+}
+
+// writeHeaders sets the headers for this error on the response headers h
+func (e *StatusError) writeHeaders(h http.Header) {
+	for k, v := range e.Headers {
+		h[k] = append([]string(nil), v...)
+	}
+}
+
 func (e *StatusError) setupFromArgs(args ...string) *StatusError {
 	if e.Err == nil {
 		e.Err = fmt.Errorf("Error:%d", e.Status)
@@ -73,6 +141,103 @@ func BadRequestError(e error, args ...string) *StatusError {
 	return err.setupFromArgs(args...)
 }
 
+// MethodNotAllowedError returns a new StatusError with Status StatusMethodNotAllowed and optional Title and Message
+func MethodNotAllowedError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusMethodNotAllowed, "Method Not Allowed", "Sorry, this page doesn't accept that kind of request.")
+	return err.setupFromArgs(args...)
+}
+
+// ForbiddenError returns a new StatusError with Status StatusForbidden and optional Title and Message
+func ForbiddenError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusForbidden, "Forbidden", "Sorry, you don't have permission to do that.")
+	return err.setupFromArgs(args...)
+}
+
+// ConflictError returns a new StatusError with Status StatusConflict and optional Title and Message
+func ConflictError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusConflict, "Conflict", "Sorry, your request conflicts with a change made by someone else.")
+	return err.setupFromArgs(args...)
+}
+
+// GoneError returns a new StatusError with Status StatusGone and optional Title and Message
+func GoneError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusGone, "Gone", "Sorry, the page you're looking for has been removed.")
+	return err.setupFromArgs(args...)
+}
+
+// PayloadTooLargeError returns a new StatusError with Status StatusRequestEntityTooLarge and optional Title and Message
+func PayloadTooLargeError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusRequestEntityTooLarge, "Payload Too Large", "Sorry, the data you sent is too large.")
+	return err.setupFromArgs(args...)
+}
+
+// UnprocessableEntityError returns a new StatusError with Status StatusUnprocessableEntity and optional Title and Message
+func UnprocessableEntityError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusUnprocessableEntity, "Unprocessable Entity", "Sorry, your data is not valid, please check it and try again.")
+	return err.setupFromArgs(args...)
+}
+
+// TooManyRequestsError returns a new StatusError with Status StatusTooManyRequests and optional Title and Message
+// If retryAfter is more than zero, a Retry-After header is set to the number of seconds to wait
+// Usage: return router.TooManyRequestsError(err, time.Minute)
+func TooManyRequestsError(e error, retryAfter time.Duration, args ...string) *StatusError {
+	err := Error(e, http.StatusTooManyRequests, "Too Many Requests", "Sorry, you've made too many requests, please try again later.")
+	if retryAfter > 0 {
+		err.WithHeader("Retry-After", retryAfterSeconds(retryAfter))
+	}
+	return err.setupFromArgs(args...)
+}
+
+// ServiceUnavailableError returns a new StatusError with Status StatusServiceUnavailable and optional Title and Message
+// Use WithHeader to set a Retry-After header if the service will be back soon
+func ServiceUnavailableError(e error, args ...string) *StatusError {
+	err := Error(e, http.StatusServiceUnavailable, "Service Unavailable", "Sorry, this service is unavailable, please try again later.")
+	return err.setupFromArgs(args...)
+}
+
+// retryAfterSeconds returns a Retry-After value for d, rounded up to whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	seconds := (d + time.Second - 1) / time.Second
+	return strconv.FormatInt(int64(seconds), 10)
+}
+
+// PanicError returns a new StatusError with Status StatusInternalServerError for a value recovered from a panic
+// It must be called from the deferred function which recovered, so that it can find where the panic happened
+func PanicError(p interface{}) *StatusError {
+	e, ok := p.(error)
+	if !ok {
+		e = fmt.Errorf("panic: %v", p)
+	}
+
+	err := &StatusError{
+		Status:  http.StatusInternalServerError,
+		Err:     e,
+		Title:   "Server Error",
+		Message: "Sorry, something went wrong, please let us know.",
+		Panic:   p,
+		Stack:   debug.Stack(),
+	}
+
+	// Find the caller of panic, skipping runtime frames
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	panicked := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicked = true
+		} else if panicked && !strings.HasPrefix(frame.Function, "runtime.") {
+			err.File, err.Line = frame.File, frame.Line
+			break
+		}
+		if !more {
+			break
+		}
+	}
+
+	return err
+}
+
 // Error returns a new StatusError with code StatusInternalServerError and a generic message
 func Error(e error, s int, t string, m string) *StatusError {
 	// Get runtime info - use zero values if none available
@@ -88,10 +253,67 @@ func Error(e error, s int, t string, m string) *StatusError {
 	return err
 }
 
-// ToStatusError returns a *StatusError or wraps a standard error in a 500 StatusError
+// ToStatusError returns the innermost *StatusError wrapped by e, or wraps a standard error in a 500 StatusError
+// Where StatusErrors are nested, a copy of the innermost is returned with the headers and extensions of all of them
+// Where the innermost status is from a sentinel error like ErrNotFound, its status, title and message are used
+// Errors from PanicError are not unwrapped, so that the panic value and stack are kept
 func ToStatusError(e error) *StatusError {
-	if err, ok := e.(*StatusError); ok {
+	// Use the status of the innermost StatusError where several are wrapped
+	var layers []*StatusError
+	inner := e
+	for inner != nil {
+		var s *StatusError
+		if !errors.As(inner, &s) {
+			break
+		}
+		layers = append(layers, s)
+		if s.Panic != nil {
+			inner = nil
+			break
+		}
+		inner = s.Err
+	}
+
+	// Sentinels wrapped by the innermost StatusError, or by e, take precedence
+	var sentinel *statusSentinel
+	if !errors.As(inner, &sentinel) {
+		sentinel = nil
+	}
+
+	if len(layers) == 0 {
+		if sentinel != nil {
+			return Error(e, sentinel.status, sentinel.title, sentinel.message)
+		}
+		return Error(e, http.StatusInternalServerError, "Error", "Sorry, an error occurred.")
+	}
+
+	err := layers[len(layers)-1]
+	if len(layers) == 1 && sentinel == nil {
 		return err
 	}
-	return Error(e, http.StatusInternalServerError, "Error", "Sorry, an error occurred.")
+
+	result := *err
+	result.Headers = nil
+	result.Extensions = nil
+	for _, s := range layers {
+		for k, v := range s.Headers {
+			if result.Headers == nil {
+				result.Headers = make(http.Header)
+			}
+			result.Headers[k] = append([]string(nil), v...)
+		}
+		for k, v := range s.Extensions {
+			if result.Extensions == nil {
+				result.Extensions = make(map[string]interface{})
+			}
+			result.Extensions[k] = v
+		}
+	}
+
+	if sentinel != nil {
+		result.Status = sentinel.status
+		result.Title = sentinel.title
+		result.Message = sentinel.message
+	}
+	return &result
 }
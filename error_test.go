@@ -0,0 +1,170 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestToStatusError checks the innermost status is used for wrapped errors
+func TestToStatusError(t *testing.T) {
+	notFound := NotFoundError(errors.New("missing"))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("failed"), http.StatusInternalServerError},
+		{"status error", notFound, http.StatusNotFound},
+		{"wrapped", fmt.Errorf("a: %w", fmt.Errorf("b: %w", notFound)), http.StatusNotFound},
+		{"nested status errors", InternalError(fmt.Errorf("load: %w", BadRequestError(errors.New("bad")))), http.StatusBadRequest},
+		{"sentinel", ErrNotFound, http.StatusNotFound},
+		{"wrapped sentinel", fmt.Errorf("load: %w", ErrNotAuthorized), http.StatusUnauthorized},
+		{"sentinel in status error", InternalError(fmt.Errorf("load: %w", ErrNotFound)), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if got := ToStatusError(tt.err); got.Status != tt.want {
+			t.Errorf("%s: got %d want %d", tt.name, got.Status, tt.want)
+		}
+	}
+
+	if ToStatusError(fmt.Errorf("a: %w", notFound)) != notFound {
+		t.Errorf("wrapped StatusError not returned")
+	}
+}
+
+// TestToStatusErrorMerge checks headers, extensions and locations are kept from every StatusError wrapped
+func TestToStatusErrorMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter string
+		extension  interface{}
+	}{
+		{"sentinel in status error", TooManyRequestsError(fmt.Errorf("x: %w", ErrBadRequest), time.Minute), http.StatusBadRequest, "60", nil},
+		{"nested status errors", TooManyRequestsError(NotFoundError(errors.New("missing")), time.Minute), http.StatusNotFound, "60", nil},
+		{"inner headers", fmt.Errorf("x: %w", BadRequestError(TooManyRequestsError(errors.New("limited"), time.Second))), http.StatusTooManyRequests, "1", nil},
+		{"inner overrides outer", TooManyRequestsError(TooManyRequestsError(errors.New("limited"), time.Second), time.Minute), http.StatusTooManyRequests, "1", nil},
+		{"extensions", BadRequestError(NotFoundError(errors.New("missing")).WithExtension("b", 2)).WithExtension("a", 1), http.StatusNotFound, "", map[string]interface{}{"a": 1, "b": 2}},
+	}
+
+	for _, tt := range tests {
+		got := ToStatusError(tt.err)
+		if got.Status != tt.status || got.Headers.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("%s: got %d %v want %d Retry-After %s", tt.name, got.Status, got.Headers, tt.status, tt.retryAfter)
+		}
+		if tt.extension != nil && !reflect.DeepEqual(got.Extensions, tt.extension) {
+			t.Errorf("%s: got extensions %v want %v", tt.name, got.Extensions, tt.extension)
+		}
+		if !strings.HasSuffix(got.File, "error_test.go") || got.Line == 0 {
+			t.Errorf("%s: location lost %s", tt.name, got.FileLine())
+		}
+	}
+
+	// Errors wrapped are not changed
+	outer := TooManyRequestsError(NotFoundError(errors.New("missing")), time.Minute)
+	ToStatusError(outer)
+	if inner := outer.Err.(*StatusError); inner.Headers != nil {
+		t.Errorf("inner error changed: %v", inner.Headers)
+	}
+
+	// Bare sentinels are given a location
+	if got := ToStatusError(ErrNotFound); got.Line == 0 || got.File == "" {
+		t.Errorf("sentinel has no location: %s", got.FileLine())
+	}
+
+	// Headers merged are rendered
+	r := newTestRouter(t)
+	r.Add("/limited", func(c Context) error {
+		return TooManyRequestsError(fmt.Errorf("x: %w", ErrBadRequest), time.Minute)
+	})
+	if w := serve(r, http.MethodGet, "/limited"); w.Code != http.StatusBadRequest || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("merged headers not rendered: %d %v", w.Code, w.Header())
+	}
+}
+
+// TestToStatusErrorPanic checks errors recovered from panics keep the panic value and stack
+func TestToStatusErrorPanic(t *testing.T) {
+	var err *StatusError
+	func() {
+		defer func() {
+			err = PanicError(recover())
+		}()
+		panic(NotFoundError(errors.New("missing")))
+	}()
+
+	got := ToStatusError(fmt.Errorf("a: %w", err))
+	if got != err || got.Status != http.StatusInternalServerError || got.Panic == nil || len(got.Stack) == 0 {
+		t.Errorf("panic error unwrapped: %v", got)
+	}
+
+	r := newTestRouter(t)
+	r.Add("/panic", func(c Context) error {
+		panic(NotFoundError(errors.New("missing")))
+	})
+	if w := serve(r, http.MethodGet, "/panic"); w.Code != http.StatusInternalServerError {
+		t.Errorf("panic rendered with status %d", w.Code)
+	}
+}
+
+// TestErrorsIs checks StatusErrors match sentinels with the same status
+func TestErrorsIs(t *testing.T) {
+	err := fmt.Errorf("a: %w", NotFoundError(errors.New("missing")))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrapped NotFoundError is not ErrNotFound")
+	}
+	if errors.Is(err, ErrInternal) {
+		t.Errorf("wrapped NotFoundError is ErrInternal")
+	}
+
+	sentinel := fmt.Errorf("load: %w", ErrNotFound)
+	s := ToStatusError(sentinel)
+	if !errors.Is(s, ErrNotFound) || s.Err != sentinel || s.Title != "Not Found" {
+		t.Errorf("sentinel StatusError wrong: %v", s)
+	}
+}
+
+// TestSentinelsShared checks changes to errors from sentinels do not affect the sentinels
+func TestSentinelsShared(t *testing.T) {
+	a := ToStatusError(ErrNotFound).WithHeader("X-Test", "a").WithExtension("test", "a")
+	b := ToStatusError(ErrNotFound)
+	if b.Headers != nil || b.Extensions != nil || a == b {
+		t.Errorf("StatusErrors from sentinel share state: %v %v", b.Headers, b.Extensions)
+	}
+}
+
+// TestErrorHeaders checks headers set on errors are written by the default error handler, and Allow is always set for 405s
+func TestErrorHeaders(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/users", testHandler).Post()
+	r.Add("/limited", func(c Context) error {
+		return TooManyRequestsError(errors.New("limited"), 1500*time.Millisecond)
+	})
+
+	w := serve(r, http.MethodGet, "/users")
+	if w.Code != http.StatusMethodNotAllowed || !reflect.DeepEqual(w.Header()["Allow"], []string{"POST"}) {
+		t.Errorf("Allow header wrong: %d %v", w.Code, w.Header()["Allow"])
+	}
+	if w := serve(r, http.MethodGet, "/limited"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Retry-After header wrong: %d %v", w.Code, w.Header())
+	}
+
+	// Custom error handlers decide which error headers are written, but 405 responses always have Allow
+	r.ErrorHandler = func(c Context, e error) {
+		c.Writer().WriteHeader(ToStatusError(e).Status)
+	}
+	w = serve(r, http.MethodGet, "/users")
+	if w.Code != http.StatusMethodNotAllowed || !reflect.DeepEqual(w.Header()["Allow"], []string{"POST"}) {
+		t.Errorf("Allow header wrong with custom handler: %d %v", w.Code, w.Header()["Allow"])
+	}
+	if w := serve(r, http.MethodGet, "/limited"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "" {
+		t.Errorf("Retry-After header written by custom handler: %d %v", w.Code, w.Header())
+	}
+}
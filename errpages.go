@@ -0,0 +1,124 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+// ErrorPage is the data passed to error page templates
+type ErrorPage struct {
+	// The status code, title and user-friendly message of the error
+	Status  int
+	Title   string
+	Message string
+
+	// The request which failed
+	Method  string
+	Path    string
+	Request *http.Request
+
+	// Whether we are running in production, if so the fields below are empty
+	Production bool
+
+	// The location and underlying error, and any stack trace from a panic
+	FileLine string
+	Err      string
+	Stack    string
+}
+
+// NewTemplateErrHandler returns an ErrHandler rendering html error pages with templates from the set given
+// For each error it uses the first template found named for the status (404.html), the class of status (4xx.html)
+// or error.html, and falls back to the default error page if none is found or the template fails
+// Clients which prefer JSON are sent problem details as by the default handler
+func NewTemplateErrHandler(templates *template.Template) ErrHandler {
+	return func(context Context, e error) {
+		err := ToStatusError(e)
+		context.Logf("#error %s\n", err)
+		err.writeHeaders(context.Writer().Header())
+
+		if wantsJSON(context) {
+			writeProblem(context, err)
+			return
+		}
+
+		t := lookupErrorTemplate(templates, err.Status)
+		if t == nil {
+			writeErrorHTML(context, err)
+			return
+		}
+
+		// Render to a buffer first, so that we can fall back if the template fails
+		html := &bytes.Buffer{}
+		if errTemplate := t.Execute(html, newErrorPage(context, err)); errTemplate != nil {
+			context.Logf("#error Rendering error template %s failed %s", t.Name(), errTemplate)
+			writeErrorHTML(context, err)
+			return
+		}
+
+		writer := context.Writer()
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.WriteHeader(err.Status)
+		writer.Write(html.Bytes())
+	}
+}
+
+// NewTemplateErrHandlerFS returns an ErrHandler rendering html error pages with the .html templates in fsys
+// See NewTemplateErrHandler for the template names used, if fsys contains no templates the default error page is used
+func NewTemplateErrHandlerFS(fsys fs.FS) (ErrHandler, error) {
+	matches, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return NewTemplateErrHandler(nil), nil
+	}
+
+	templates, err := template.ParseFS(fsys, matches...)
+	if err != nil {
+		return nil, err
+	}
+	return NewTemplateErrHandler(templates), nil
+}
+
+// lookupErrorTemplate returns the template for this status, or nil if there is none
+func lookupErrorTemplate(templates *template.Template, status int) *template.Template {
+	if templates == nil {
+		return nil
+	}
+	names := []string{
+		fmt.Sprintf("%d.html", status),
+		fmt.Sprintf("%dxx.html", status/100),
+		"error.html",
+	}
+	for _, name := range names {
+		if t := templates.Lookup(name); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// newErrorPage returns the data for an error page template, revealing the real error only if NOT in production
+func newErrorPage(context Context, err *StatusError) *ErrorPage {
+	request := context.Request()
+	page := &ErrorPage{
+		Status:     err.Status,
+		Title:      err.Title,
+		Message:    err.Message,
+		Method:     request.Method,
+		Path:       context.Path(),
+		Request:    request,
+		Production: context.Production(),
+	}
+
+	if !page.Production {
+		page.FileLine = err.FileLine()
+		page.Err = fmt.Sprint(err.Err)
+		page.Stack = string(err.Stack)
+	}
+
+	return page
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// errorPagesRouter returns a router rendering errors with handler, with routes returning each kind of error
+func errorPagesRouter(t *testing.T, handler ErrHandler, production bool) *Router {
+	r, err := New(testLogger{}, testConfig{production: production}, WithErrorHandler(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Add("/401", func(c Context) error { return NotAuthorizedError(errors.New("no user")) })
+	r.Add("/403", func(c Context) error { return ForbiddenError(errors.New("not admin")) })
+	r.Add("/500", func(c Context) error { return InternalError(errors.New("database down")) })
+	r.Add("/503", func(c Context) error {
+		return ServiceUnavailableError(errors.New("maintenance")).WithHeader("Retry-After", "120")
+	})
+	return r
+}
+
+// TestTemplateErrHandler checks templates are chosen by status, then class of status, then error.html
+func TestTemplateErrHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"404.html":   {Data: []byte(`404 {{.Method}} {{.Path}}`)},
+		"403.html":   {Data: []byte(`403 {{.Title}}`)},
+		"4xx.html":   {Data: []byte(`4xx {{.Status}} {{.Message}}`)},
+		"error.html": {Data: []byte(`error {{.Status}} {{.Title}}`)},
+		"notes.txt":  {Data: []byte(`ignored`)},
+	}
+	handler, err := NewTemplateErrHandlerFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := errorPagesRouter(t, handler, true)
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/missing", http.StatusNotFound, "404 GET /missing"},
+		{"/403", http.StatusForbidden, "403 Forbidden"},
+		{"/401", http.StatusUnauthorized, "4xx 401 Sorry, I can&#39;t let you do that."},
+		{"/500", http.StatusInternalServerError, "error 500 Server Error"},
+		{"/503", http.StatusServiceUnavailable, "error 503 Service Unavailable"},
+	}
+	for _, tt := range tests {
+		w := serve(r, http.MethodGet, tt.path)
+		if w.Code != tt.status || w.Body.String() != tt.want || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%s: got %d %q want %d %q", tt.path, w.Code, w.Body.String(), tt.status, tt.want)
+		}
+	}
+
+	if w := serve(r, http.MethodGet, "/503"); w.Header().Get("Retry-After") != "120" {
+		t.Errorf("error headers not written: %v", w.Header())
+	}
+
+	// JSON clients get problem details
+	request := httptest.NewRequest(http.MethodGet, "/403", nil)
+	request.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, request)
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("JSON client got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+// TestTemplateErrHandlerProduction checks the real error is only passed to templates outside production
+func TestTemplateErrHandlerProduction(t *testing.T) {
+	templates := template.Must(template.New("error.html").Parse(`{{.Status}}|{{.Err}}|{{.FileLine}}`))
+	for _, production := range []bool{false, true} {
+		r := errorPagesRouter(t, NewTemplateErrHandler(templates), production)
+		body := serve(r, http.MethodGet, "/500").Body.String()
+		if production && body != "500||" {
+			t.Errorf("production page shows error: %s", body)
+		}
+		if !production && (!strings.HasPrefix(body, "500|database down|") || !strings.Contains(body, "errpages_test.go:")) {
+			t.Errorf("development page hides error: %s", body)
+		}
+	}
+}
+
+// TestTemplateErrHandlerFallback checks the default page is used if there is no template or it fails
+func TestTemplateErrHandlerFallback(t *testing.T) {
+	failing := template.Must(template.New("500.html").Parse(`partial {{.Missing}}`))
+	template.Must(failing.New("404.html").Parse(`not found`))
+
+	tests := []struct {
+		name    string
+		handler ErrHandler
+	}{
+		{"nil templates", NewTemplateErrHandler(nil)},
+		{"template fails", NewTemplateErrHandler(failing)},
+	}
+	for _, tt := range tests {
+		r := errorPagesRouter(t, tt.handler, true)
+		w := serve(r, http.MethodGet, "/500")
+		if w.Code != http.StatusInternalServerError || w.Body.String() != "<h1>Server Error</h1><p>Sorry, something went wrong, please let us know.</p>" {
+			t.Errorf("%s: got %d %q", tt.name, w.Code, w.Body.String())
+		}
+	}
+
+	// Templates which are not found fall back too
+	r := errorPagesRouter(t, NewTemplateErrHandler(failing), true)
+	if w := serve(r, http.MethodGet, "/403"); w.Code != http.StatusForbidden || !strings.HasPrefix(w.Body.String(), "<h1>Forbidden</h1>") {
+		t.Errorf("missing template: got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(r, http.MethodGet, "/missing"); w.Body.String() != "not found" {
+		t.Errorf("404 template not used: %q", w.Body.String())
+	}
+}
+
+// TestNewTemplateErrHandlerFS checks empty and invalid template directories
+func TestNewTemplateErrHandlerFS(t *testing.T) {
+	handler, err := NewTemplateErrHandlerFS(fstest.MapFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := errorPagesRouter(t, handler, true)
+	if w := serve(r, http.MethodGet, "/missing"); w.Code != http.StatusNotFound || !strings.HasPrefix(w.Body.String(), "<h1>Not Found</h1>") {
+		t.Errorf("empty fs: got %d %q", w.Code, w.Body.String())
+	}
+
+	_, err = NewTemplateErrHandlerFS(fstest.MapFS{"404.html": {Data: []byte(`{{.Broken`)}})
+	if err == nil {
+		t.Errorf("invalid template did not fail")
+	}
+}
@@ -0,0 +1,131 @@
+package router
+
+// Group adds routes to a router sharing a pattern prefix, default methods and filters
+// Routes added to a group are stored in the router's routes, the group only sets them up
+type Group struct {
+	// The router which stores routes for this group
+	router *Router
+
+	// The enclosing group, or nil for top level groups
+	parent *Group
+
+	// The prefix for all patterns in this group, including any parent prefix
+	prefix string
+
+	// The default methods for routes in this group, nil to use the route defaults
+	methods []string
+
+	// The host pattern for routes in this group, if any
+	host string
+
+	// A list of filters applied before handlers for routes in this group
+	filters []Handler
+}
+
+// Group creates a new group of routes with this prefix, and calls setup to add routes to it
+// Usage: r.Group("/admin", func(g *router.Group) { g.Add("/users", handler) })
+func (r *Router) Group(prefix string, setup func(*Group)) *Group {
+	g := &Group{
+		router: r,
+		prefix: prefix,
+	}
+	if setup != nil {
+		setup(g)
+	}
+	return g
+}
+
+// Group creates a group nested within this one, with its prefix appended to ours
+// Routes in the nested group run our filters, followed by its own
+func (g *Group) Group(prefix string, setup func(*Group)) *Group {
+	nested := &Group{
+		router:  g.router,
+		parent:  g,
+		prefix:  joinPattern(g.prefix, prefix),
+		methods: g.methods,
+		host:    g.host,
+	}
+	if setup != nil {
+		setup(nested)
+	}
+	return nested
+}
+
+// Host creates a group of routes restricted to hosts matching pattern, and calls setup to add routes to it
+// Usage: r.Host("api.example.com", func(g *router.Group) { g.Add("/users", handler) })
+func (r *Router) Host(pattern string, setup func(*Group)) *Group {
+	return r.Group("", func(g *Group) {
+		g.Host(pattern)
+		if setup != nil {
+			setup(g)
+		}
+	})
+}
+
+// Host restricts routes subsequently added to this group to hosts matching pattern
+func (g *Group) Host(pattern string) *Group {
+	g.host = pattern
+	return g
+}
+
+// Prefix returns the pattern prefix for routes in this group
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Methods sets the default methods for routes subsequently added to this group
+func (g *Group) Methods(permitted ...string) *Group {
+	g.methods = permitted
+	return g
+}
+
+// Add a new route to the router with the group prefix
+func (g *Group) Add(pattern string, handler Handler) *Route {
+	return g.setup(g.router.Add(joinPattern(g.prefix, pattern), handler))
+}
+
+// AddRedirect adds a new redirect to the router with the group prefix
+func (g *Group) AddRedirect(pattern string, redirectPath string, status int) *Route {
+	return g.setup(g.router.AddRedirect(joinPattern(g.prefix, pattern), redirectPath, status))
+}
+
+// AddFilter adds a new filter to execute before handlers for routes in this group
+func (g *Group) AddFilter(filter Handler) {
+	g.router.mu.Lock()
+	defer g.router.mu.Unlock()
+	g.filters = append(g.filters, filter)
+}
+
+// setup applies the group settings to a route added to the router
+func (g *Group) setup(route *Route) *Route {
+	if route == nil {
+		return nil
+	}
+	route.group = g
+	if g.host != "" {
+		route.Host(g.host)
+	}
+	if g.methods != nil {
+		route.Methods(append([]string{}, g.methods...)...)
+	}
+	return route
+}
+
+// chain returns the filters for this group, starting with those of the outermost group
+func (g *Group) chain() []Handler {
+	if g == nil {
+		return nil
+	}
+	return append(g.parent.chain(), g.filters...)
+}
+
+// joinPattern appends pattern to prefix, so that a pattern of / refers to the prefix itself
+func joinPattern(prefix, pattern string) string {
+	if prefix == "" {
+		return pattern
+	}
+	if pattern == "" || pattern == "/" {
+		return prefix
+	}
+	return prefix + pattern
+}
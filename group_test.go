@@ -0,0 +1,93 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGroupPatterns checks nested group prefixes, methods and hosts are applied to routes
+func TestGroupPatterns(t *testing.T) {
+	r := newTestRouter(t)
+	var routes []*Route
+	var nested *Group
+	r.Group("/admin", func(g *Group) {
+		routes = append(routes, g.Add("/", testHandler))
+		routes = append(routes, g.Add("/users", testHandler))
+		g.Methods(http.MethodPost)
+		nested = g.Group("/pages", func(g *Group) {
+			routes = append(routes, g.Add("", testHandler))
+			routes = append(routes, g.Add("/{id:int}", testHandler))
+			routes = append(routes, g.AddRedirect("/old", "/admin/pages", http.StatusMovedPermanently))
+		})
+	})
+	r.Host("api.example.com", func(g *Group) {
+		routes = append(routes, g.Add("/users", testHandler))
+	})
+
+	tests := []struct {
+		pattern string
+		methods []string
+		host    string
+	}{
+		{"/admin", []string{"GET", "HEAD"}, ""},
+		{"/admin/users", []string{"GET", "HEAD"}, ""},
+		{"/admin/pages", []string{"POST"}, ""},
+		{"/admin/pages/{id:int}", []string{"POST"}, ""},
+		{"/admin/pages/old", []string{"POST"}, ""},
+		{"/users", []string{"GET", "HEAD"}, "api.example.com"},
+	}
+	for i, tt := range tests {
+		route := routes[i]
+		if route.Pattern != tt.pattern || !reflect.DeepEqual(route.AllowedMethods(), tt.methods) || route.host != tt.host {
+			t.Errorf("route %d: got %s %v %q want %s %v %q", i, route.Pattern, route.AllowedMethods(), route.host, tt.pattern, tt.methods, tt.host)
+		}
+	}
+
+	if nested.Prefix() != "/admin/pages" {
+		t.Errorf("nested prefix got %s", nested.Prefix())
+	}
+
+	if w := serve(r, http.MethodPost, "/admin/pages/3"); w.Code != http.StatusOK {
+		t.Errorf("nested group route not found: %d", w.Code)
+	}
+}
+
+// TestGroupFilters checks routes run the filters of their enclosing groups, outermost first
+func TestGroupFilters(t *testing.T) {
+	var calls []string
+	r := newTestRouter(t)
+	r.Group("/a", func(a *Group) {
+		a.AddFilter(recordFilter(&calls, "a", nil))
+		a.Add("/x", recordFilter(&calls, "ax", nil))
+		a.Group("/b", func(b *Group) {
+			b.AddFilter(recordFilter(&calls, "b", nil))
+			b.Group("/c", func(c *Group) {
+				c.AddFilter(recordFilter(&calls, "c", nil))
+				c.Add("/x", recordFilter(&calls, "abcx", nil))
+			})
+		})
+		// Filters added later still apply to routes added earlier
+		a.AddFilter(recordFilter(&calls, "a2", nil))
+	})
+	r.Group("/d", func(d *Group) {
+		d.Add("/x", recordFilter(&calls, "dx", nil))
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/a/x", "a a2 ax"},
+		{"/a/b/c/x", "a a2 b c abcx"},
+		{"/d/x", "dx"},
+	}
+	for _, tt := range tests {
+		calls = nil
+		serve(r, http.MethodGet, tt.path)
+		if got := strings.Join(calls, " "); got != tt.want {
+			t.Errorf("%s: got %s want %s", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package router
+
+// RouteInfo describes a route, for tools which list or check the routes in a router
+// It is a copy, so changing it does not change the route
+type RouteInfo struct {
+	// The path pattern for the route
+	Pattern string
+
+	// The name used to generate URLs, if any
+	Name string
+
+	// The host pattern the route is restricted to, if any
+	Host string
+
+	// The HTTP methods the route accepts
+	Methods []string
+
+	// The params captured by the route from the path, host, headers and query
+	Params []ParamInfo
+
+	// The schemes the route is restricted to, if any
+	Schemes []string
+
+	// The header and query values the route requires
+	Headers []ValueInfo
+	Queries []ValueInfo
+
+	// The handler for the route, nil for redirects
+	Handler Handler
+
+	// The redirect path and status, for redirect routes
+	RedirectPath   string
+	RedirectStatus int
+
+	// The number of filters run before the handler, including group filters, and after it
+	Filters      int
+	AfterFilters int
+
+	// Metadata stored with Route.Meta
+	Meta map[string]interface{}
+}
+
+// ParamInfo describes a param captured by a route
+type ParamInfo struct {
+	// The name of the param
+	Name string
+
+	// The regexp matching values of the param
+	Pattern string
+
+	// The name of the registered type of the param, if any
+	Type string
+
+	// Where the param is taken from - path, host, header or query
+	In string
+
+	// Whether the param is a catch-all matching the rest of the path
+	CatchAll bool
+}
+
+// ValueInfo describes a header or query value required by a route
+type ValueInfo struct {
+	// The header or query key
+	Key string
+
+	// The pattern for the value, empty to match any value
+	Pattern string
+
+	// The regexp matching whole values, empty to match any value
+	Regexp string
+
+	// The params captured from the value
+	Params []ParamInfo
+}
+
+// Routes returns descriptions of all the routes in the router, in the order they were added
+func (r *Router) Routes() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]RouteInfo, len(r.routes))
+	for i, route := range r.routes {
+		infos[i] = route.Info()
+	}
+	return infos
+}
+
+// Info returns a description of this route
+func (r *Route) Info() RouteInfo {
+	info := RouteInfo{
+		Pattern:        r.Pattern,
+		Name:           r.name,
+		Host:           r.host,
+		Methods:        r.AllowedMethods(),
+		Schemes:        append([]string(nil), r.schemes...),
+		Handler:        r.Handler,
+		RedirectPath:   r.RedirectPath,
+		RedirectStatus: r.RedirectStatus,
+		Filters:        len(r.filterChain()),
+		AfterFilters:   len(r.afterFilters),
+	}
+
+	for _, t := range r.tokens() {
+		if t.param != nil {
+			info.Params = append(info.Params, t.param.info("path"))
+		}
+	}
+	if r.hostMatcher != nil {
+		for _, p := range r.hostMatcher.params {
+			info.Params = append(info.Params, p.info("host"))
+		}
+	}
+	for _, m := range r.headers {
+		value := m.info("header")
+		info.Headers = append(info.Headers, value)
+		info.Params = append(info.Params, value.Params...)
+	}
+	for _, m := range r.queries {
+		value := m.info("query")
+		info.Queries = append(info.Queries, value)
+		info.Params = append(info.Params, value.Params...)
+	}
+
+	if r.meta != nil {
+		info.Meta = make(map[string]interface{}, len(r.meta))
+		for k, v := range r.meta {
+			info.Meta[k] = v
+		}
+	}
+
+	return info
+}
+
+// info returns a description of this param, found in the part of the request given
+func (p routeParam) info(in string) ParamInfo {
+	info := ParamInfo{
+		Name:     p.name,
+		Pattern:  p.pattern,
+		In:       in,
+		CatchAll: p.catchAll,
+	}
+	if p.paramType != nil {
+		info.Type = p.paramType.Name
+	}
+	return info
+}
+
+// info returns a description of the value matched, which is found in the part of the request given
+func (m *valueMatcher) info(in string) ValueInfo {
+	info := ValueInfo{
+		Key:     m.key,
+		Pattern: m.pattern,
+	}
+	if m.regexp != nil {
+		info.Regexp = m.regexp.String()
+	}
+	for _, p := range m.params {
+		info.Params = append(info.Params, p.info(in))
+	}
+	return info
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RequestMatcher reports whether a request matches, used to choose requests the router skips logging or routing
+type RequestMatcher func(*http.Request) bool
+
+// MatchPrefixes returns a RequestMatcher for requests with a cleaned path starting with any of the prefixes
+func MatchPrefixes(prefixes ...string) RequestMatcher {
+	return func(request *http.Request) bool {
+		p := cleanPath(request.URL.Path)
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchGlobs returns a RequestMatcher for requests with a cleaned path matching any of the patterns
+// Patterns use the syntax of path.Match, for example /assets/*/*.js
+func MatchGlobs(patterns ...string) RequestMatcher {
+	return func(request *http.Request) bool {
+		p := cleanPath(request.URL.Path)
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchAny returns a RequestMatcher for requests matched by any of the matchers
+func MatchAny(matchers ...RequestMatcher) RequestMatcher {
+	return func(request *http.Request) bool {
+		for _, m := range matchers {
+			if m != nil && m(request) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matches returns true if the matcher is set and matches the request
+func (m RequestMatcher) matches(request *http.Request) bool {
+	return m != nil && m(request)
+}
+
+// cleanPath cleans the request path and makes sure it starts with /
+func cleanPath(p string) string {
+	canonicalPath := path.Clean(p)
+	if len(canonicalPath) == 0 {
+		canonicalPath = "/"
+	} else if canonicalPath[0] != '/' {
+		canonicalPath = "/" + canonicalPath
+	}
+	return canonicalPath
+}
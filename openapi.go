@@ -0,0 +1,365 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metadata keys read from routes by OpenAPI, set them with Route.Meta
+// Schemas may be any value which encodes to a JSON schema, such as a map[string]interface{}
+const (
+	// MetaSummary is a short summary of the operation (string)
+	MetaSummary = "openapi.summary"
+
+	// MetaDescription is a longer description of the operation (string)
+	MetaDescription = "openapi.description"
+
+	// MetaTags are tags used to group operations ([]string or string)
+	MetaTags = "openapi.tags"
+
+	// MetaRequestSchema is the schema of a JSON request body
+	MetaRequestSchema = "openapi.request"
+
+	// MetaResponseSchema is the schema of a JSON response body
+	MetaResponseSchema = "openapi.response"
+)
+
+// OpenAPIDocument is an OpenAPI 3 document describing the routes in a router
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo describes the API in an OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIOperation describes a method on a path in an OpenAPI document
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path, query or header param in an OpenAPI document
+type OpenAPIParameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a request body in an OpenAPI document
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response in an OpenAPI document
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema for a request or response body in an OpenAPI document
+type OpenAPIMediaType struct {
+	Schema interface{} `json:"schema"`
+}
+
+// OpenAPI returns an OpenAPI 3 document describing the routes in the router
+// Patterns become path templates, with param schemas taken from their type or regexp
+// Redirects are left out, as are HEAD methods on routes which accept GET, and routes with path params
+// which may contain a slash, such as catch-alls, which OpenAPI path params cannot describe
+// Accept and Content-Type headers without params become the response and request content types,
+// where several routes share a path and method the first route added is used, with the content types of all of them
+// Operation IDs are the route name, followed by the method if the route has more than one
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	for _, route := range r.Routes() {
+		if route.RedirectStatus != 0 || !openAPIPathParams(route) {
+			continue
+		}
+
+		path := openAPIPath(route.Pattern)
+		operations := doc.Paths[path]
+		if operations == nil {
+			operations = make(map[string]*OpenAPIOperation)
+			doc.Paths[path] = operations
+		}
+
+		var methods []string
+		for _, method := range route.Methods {
+			if method != http.MethodHead || !containsString(route.Methods, http.MethodGet) {
+				methods = append(methods, method)
+			}
+		}
+
+		for _, method := range methods {
+			key := strings.ToLower(method)
+			op := operations[key]
+			if op == nil {
+				op = newOpenAPIOperation(route)
+				if op.OperationID != "" && len(methods) > 1 {
+					op.OperationID += "." + key
+				}
+				operations[key] = op
+				continue
+			}
+
+			// Add the content types of later routes, e.g. for versions selected by Accept
+			if contentType := openAPIContentType(route.Headers, "Accept"); contentType != "" {
+				response := op.Responses["200"]
+				if response.Content == nil {
+					response.Content = map[string]OpenAPIMediaType{}
+				}
+				if _, ok := response.Content[contentType]; !ok {
+					response.Content[contentType] = OpenAPIMediaType{Schema: openAPIBodySchema(route, MetaResponseSchema)}
+				}
+				op.Responses["200"] = response
+			}
+		}
+	}
+
+	return doc
+}
+
+// JSON returns the document encoded as indented JSON
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the document encoded as YAML, with keys sorted
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode to generic values so that schemas supplied as structs are encoded consistently
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	b := &bytes.Buffer{}
+	writeYAML(b, value, 0)
+	return b.Bytes(), nil
+}
+
+// newOpenAPIOperation returns the operation for a route, using its params and metadata
+func newOpenAPIOperation(route RouteInfo) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: route.Name,
+		Responses:   map[string]OpenAPIResponse{},
+	}
+
+	op.Summary, _ = route.Meta[MetaSummary].(string)
+	op.Description, _ = route.Meta[MetaDescription].(string)
+	switch tags := route.Meta[MetaTags].(type) {
+	case []string:
+		op.Tags = tags
+	case string:
+		op.Tags = []string{tags}
+	}
+
+	// Host params cannot be described by OpenAPI parameters
+	for _, p := range route.Params {
+		if p.In == "path" {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     p.Name,
+				In:       p.In,
+				Required: true,
+				Schema:   openAPISchema(p),
+			})
+		}
+	}
+
+	// OpenAPI ignores parameters for these headers, Accept and Content-Type are described as content types instead
+	for _, v := range route.Headers {
+		if v.Key != "Accept" && v.Key != "Content-Type" && v.Key != "Authorization" {
+			op.Parameters = append(op.Parameters, newOpenAPIValueParameter(v, "header"))
+		}
+	}
+	for _, v := range route.Queries {
+		op.Parameters = append(op.Parameters, newOpenAPIValueParameter(v, "query"))
+	}
+
+	requestType := openAPIContentType(route.Headers, "Content-Type")
+	if _, ok := route.Meta[MetaRequestSchema]; ok || requestType != "" {
+		if requestType == "" {
+			requestType = "application/json"
+		}
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{requestType: {Schema: openAPIBodySchema(route, MetaRequestSchema)}},
+		}
+	}
+
+	response := OpenAPIResponse{Description: "OK"}
+	responseType := openAPIContentType(route.Headers, "Accept")
+	if _, ok := route.Meta[MetaResponseSchema]; ok || responseType != "" {
+		if responseType == "" {
+			responseType = "application/json"
+		}
+		response.Content = map[string]OpenAPIMediaType{responseType: {Schema: openAPIBodySchema(route, MetaResponseSchema)}}
+	}
+	op.Responses["200"] = response
+
+	return op
+}
+
+// openAPIContentType returns the media type required by the route in the header key, or "" if the pattern has params
+func openAPIContentType(headers []ValueInfo, key string) string {
+	for _, v := range headers {
+		if v.Key == key && len(v.Params) == 0 {
+			return v.Pattern
+		}
+	}
+	return ""
+}
+
+// openAPIBodySchema returns the schema stored in the route metadata at key, or an empty schema
+func openAPIBodySchema(route RouteInfo, key string) interface{} {
+	if schema, ok := route.Meta[key]; ok {
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// openAPIPathParams returns true if the path params of the route cannot contain a slash, so may be OpenAPI path params
+func openAPIPathParams(route RouteInfo) bool {
+	for _, p := range route.Params {
+		if p.In != "path" {
+			continue
+		}
+		re, err := syntax.Parse(p.Pattern, syntax.Perl)
+		if p.CatchAll || err != nil || matchesSlash(re) {
+			return false
+		}
+	}
+	return true
+}
+
+// newOpenAPIValueParameter returns the parameter for a header or query value required by a route
+// Values which are a single param use the schema for the param, others are strings matching the value regexp
+func newOpenAPIValueParameter(v ValueInfo, in string) OpenAPIParameter {
+	schema := map[string]interface{}{"type": "string"}
+	if len(v.Params) == 1 && strings.HasPrefix(v.Pattern, "{") && strings.HasSuffix(v.Pattern, "}") {
+		schema = openAPISchema(v.Params[0])
+	} else if v.Regexp != "" {
+		schema["pattern"] = v.Regexp
+	}
+
+	return OpenAPIParameter{
+		Name:     v.Key,
+		In:       in,
+		Required: true,
+		Schema:   schema,
+	}
+}
+
+// openAPIPath converts a route pattern to an OpenAPI path template, e.g. /tags/{id:[0-9]+} to /tags/{id}
+func openAPIPath(pattern string) string {
+	route := &Route{Pattern: pattern}
+	path := ""
+	for _, t := range route.tokens() {
+		if t.param == nil {
+			path += t.literal
+		} else {
+			path += "{" + t.param.name + "}"
+		}
+	}
+	return path
+}
+
+// openAPISchema returns a schema for the param, from its type or regexp
+func openAPISchema(p ParamInfo) map[string]interface{} {
+	switch p.Type {
+	case "int":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "uint":
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case "uuid":
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case "date":
+		return map[string]interface{}{"type": "string", "format": "date"}
+	}
+
+	switch p.Pattern {
+	case "[0-9]+", `\d+`:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case ".*", ".+", defaultParamPattern, hostParamPattern:
+		return map[string]interface{}{"type": "string"}
+	}
+
+	return map[string]interface{}{"type": "string", "pattern": "^(?:" + p.Pattern + ")$"}
+}
+
+// writeYAML writes a value decoded from JSON as YAML, indented by indent levels
+func writeYAML(b *bytes.Buffer, value interface{}, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(b, "%s%s:", prefix, strconv.Quote(k))
+			writeYAMLValue(b, v[k], indent+1)
+		}
+	case []interface{}:
+		for _, item := range v {
+			fmt.Fprintf(b, "%s-", prefix)
+			writeYAMLValue(b, item, indent+1)
+		}
+	}
+}
+
+// writeYAMLValue writes a value following a key or list marker, on the same line if it is a scalar
+func writeYAMLValue(b *bytes.Buffer, value interface{}, indent int) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent)
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent)
+	case string:
+		fmt.Fprintf(b, " %s\n", strconv.Quote(v))
+	case nil:
+		b.WriteString(" null\n")
+	default:
+		fmt.Fprintf(b, " %v\n", v)
+	}
+}
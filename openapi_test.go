@@ -0,0 +1,213 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestOpenAPIJSON checks the operations and parameters in the JSON document for routes
+func TestOpenAPIJSON(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/tags/{id:int}", testHandler).Name("tag.show").Meta(MetaSummary, "Show a tag").Meta(MetaTags, "tags")
+	r.Add("/tags/{id:[0-9]+}/destroy", testHandler).Post().Meta(MetaRequestSchema, map[string]interface{}{"type": "object"})
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v{version:int}+json").Header("X-Key", "k{key}")
+	r.Add("/tags/{id:int}/update", testHandler).Methods("POST", "PUT").Name("tag.update")
+	r.Add("/search", testHandler).Query("page", "{page:int}").Query("sort", "{field}-{dir}").Query("flag", "")
+	r.Add("/docs/{path...}", testHandler).Host("{tenant}.example.com")
+	r.AddRedirect("/old", "/new", 301)
+
+	data, err := r.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"}).JSON()
+	if err != nil {
+		t.Fatalf("JSON failed: %s", err)
+	}
+
+	var doc struct {
+		OpenAPI string
+		Paths   map[string]map[string]struct {
+			OperationID string
+			Summary     string
+			Tags        []string
+			Parameters  []struct {
+				Name     string
+				In       string
+				Required bool
+				Schema   map[string]interface{}
+			}
+			RequestBody *struct{ Required bool }
+			Responses   map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON invalid: %s\n%s", err, data)
+	}
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi version wrong: %s", doc.OpenAPI)
+	}
+	if len(doc.Paths) != 5 || doc.Paths["/old"] != nil || doc.Paths["/docs/{path}"] != nil {
+		t.Errorf("paths wrong: %v", doc.Paths)
+	}
+	if len(doc.Paths["/tags/{id}"]) != 1 {
+		t.Errorf("HEAD not left out for GET route: %v", doc.Paths["/tags/{id}"])
+	}
+
+	show := doc.Paths["/tags/{id}"]["get"]
+	if show.OperationID != "tag.show" || show.Summary != "Show a tag" || !reflect.DeepEqual(show.Tags, []string{"tags"}) || show.Responses["200"] == nil {
+		t.Errorf("show operation wrong: %+v", show)
+	}
+	if destroy := doc.Paths["/tags/{id}/destroy"]["post"]; destroy.RequestBody == nil || !destroy.RequestBody.Required {
+		t.Errorf("request body missing: %+v", destroy)
+	}
+
+	update := doc.Paths["/tags/{id}/update"]
+	if update["post"].OperationID != "tag.update.post" || update["put"].OperationID != "tag.update.put" {
+		t.Errorf("operation ids not unique: %s %s", update["post"].OperationID, update["put"].OperationID)
+	}
+
+	type param struct {
+		name   string
+		in     string
+		schema map[string]interface{}
+	}
+	tests := []struct {
+		path string
+		want []param
+	}{
+		{"/tags/{id}", []param{{"id", "path", map[string]interface{}{"type": "integer", "format": "int64"}}}},
+		{"/users", []param{{"X-Key", "header", map[string]interface{}{"type": "string", "pattern": "^k(.+)$"}}}},
+		{"/search", []param{
+			{"page", "query", map[string]interface{}{"type": "integer", "format": "int64"}},
+			{"sort", "query", map[string]interface{}{"type": "string", "pattern": "^(.+)-(.+)$"}},
+			{"flag", "query", map[string]interface{}{"type": "string"}},
+		}},
+	}
+	for _, tt := range tests {
+		op := doc.Paths[tt.path]["get"]
+		if len(op.Parameters) != len(tt.want) {
+			t.Errorf("%s: got %d params want %d: %+v", tt.path, len(op.Parameters), len(tt.want), op.Parameters)
+			continue
+		}
+		for i, want := range tt.want {
+			got := op.Parameters[i]
+			if got.Name != want.name || got.In != want.in || !got.Required || !reflect.DeepEqual(got.Schema, want.schema) {
+				t.Errorf("%s: param %d got %+v want %+v", tt.path, i, got, want)
+			}
+		}
+	}
+}
+
+// TestOpenAPIContentTypes checks Accept and Content-Type headers are described as content types
+func TestOpenAPIContentTypes(t *testing.T) {
+	schema := map[string]interface{}{"type": "object"}
+	r := newTestRouter(t)
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v1+json").Meta(MetaResponseSchema, schema)
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v2+json")
+	r.Add("/users", testHandler).Header("Accept", "application/vnd.example.v{version:int}+json")
+	r.Add("/users/create", testHandler).Post().Header("Content-Type", "application/xml").Header("Authorization", "")
+	r.Add("/files/{path:.+}", testHandler)
+
+	doc := r.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	if len(doc.Paths) != 2 {
+		t.Errorf("paths wrong: %v", doc.Paths)
+	}
+
+	users := doc.Paths["/users"]["get"]
+	want := map[string]OpenAPIMediaType{
+		"application/vnd.example.v1+json": {Schema: schema},
+		"application/vnd.example.v2+json": {Schema: map[string]interface{}{}},
+	}
+	if len(users.Parameters) != 0 || !reflect.DeepEqual(users.Responses["200"].Content, want) {
+		t.Errorf("response content types wrong: %v %v", users.Parameters, users.Responses["200"].Content)
+	}
+
+	create := doc.Paths["/users/create"]["post"]
+	if len(create.Parameters) != 0 || create.RequestBody == nil || create.RequestBody.Content["application/xml"].Schema == nil {
+		t.Errorf("request content type wrong: %v %+v", create.Parameters, create.RequestBody)
+	}
+}
+
+// TestOpenAPIYAML checks the YAML written for a document
+func TestOpenAPIYAML(t *testing.T) {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: "Test: \"quoted\"", Version: "1.0"},
+		Paths: map[string]map[string]*OpenAPIOperation{
+			"/tags/{id}": {
+				"get": {
+					Tags: []string{"tags"},
+					Parameters: []OpenAPIParameter{
+						{Name: "id", In: "path", Required: true, Schema: map[string]interface{}{"type": "integer", "minimum": 0}},
+					},
+					Responses: map[string]OpenAPIResponse{
+						"200": {Description: "OK", Content: map[string]OpenAPIMediaType{
+							"application/json": {Schema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}, "enum": []interface{}{}, "default": nil}},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	want := `"info":
+  "title": "Test: \"quoted\""
+  "version": "1.0"
+"openapi": "3.0.3"
+"paths":
+  "/tags/{id}":
+    "get":
+      "parameters":
+        -
+          "in": "path"
+          "name": "id"
+          "required": true
+          "schema":
+            "minimum": 0
+            "type": "integer"
+      "responses":
+        "200":
+          "content":
+            "application/json":
+              "schema":
+                "default": null
+                "enum": []
+                "properties": {}
+                "type": "object"
+          "description": "OK"
+      "tags":
+        - "tags"
+`
+
+	data, err := doc.YAML()
+	if err != nil {
+		t.Fatalf("YAML failed: %s", err)
+	}
+	if string(data) != want {
+		t.Errorf("YAML wrong, got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+// TestWriteYAML checks scalars, lists and maps are written as YAML
+func TestWriteYAML(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{map[string]interface{}{}, ""},
+		{map[string]interface{}{"a": "b"}, "\"a\": \"b\"\n"},
+		{map[string]interface{}{"b": json.Number("1.5"), "a": true}, "\"a\": true\n\"b\": 1.5\n"},
+		{map[string]interface{}{"a": "line\nbreak"}, "\"a\": \"line\\nbreak\"\n"},
+		{[]interface{}{"a", nil}, "- \"a\"\n- null\n"},
+		{[]interface{}{[]interface{}{"a"}}, "-\n  - \"a\"\n"},
+		{map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": "c", "d": "e"}}}, "\"a\":\n  -\n    \"b\": \"c\"\n    \"d\": \"e\"\n"},
+	}
+
+	for _, tt := range tests {
+		b := &bytes.Buffer{}
+		writeYAML(b, tt.value, 0)
+		if b.String() != tt.want {
+			t.Errorf("%v: got %q want %q", tt.value, b.String(), tt.want)
+		}
+	}
+}
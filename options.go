@@ -0,0 +1,73 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Option configures a router created with New
+type Option func(*Router) error
+
+// WithFileHandler sets the handler used when no route matches a request
+func WithFileHandler(handler Handler) Option {
+	return func(r *Router) error {
+		r.FileHandler = handler
+		return nil
+	}
+}
+
+// WithErrorHandler sets the handler used to render errors
+func WithErrorHandler(handler ErrHandler) Option {
+	return func(r *Router) error {
+		r.ErrorHandler = handler
+		return nil
+	}
+}
+
+// WithOptionsHandler sets the handler used to respond to OPTIONS requests for routes
+func WithOptionsHandler(handler Handler) Option {
+	return func(r *Router) error {
+		r.OptionsHandler = handler
+		return nil
+	}
+}
+
+// WithSkipLogging sets the matcher for requests which are not logged, nil to log all requests
+func WithSkipLogging(matcher RequestMatcher) Option {
+	return func(r *Router) error {
+		r.SkipLogging = matcher
+		return nil
+	}
+}
+
+// WithSkipRouting sets the matcher for requests which go straight to the file handler, nil to route all requests
+func WithSkipRouting(matcher RequestMatcher) Option {
+	return func(r *Router) error {
+		r.SkipRouting = matcher
+		return nil
+	}
+}
+
+// WithTrustedProxies sets the matcher for requests from trusted proxies, whose X-Forwarded-Proto header is used for route schemes
+// Usage: router.WithTrustedProxies(func(r *http.Request) bool { return strings.HasPrefix(r.RemoteAddr, "10.0.0.1:") })
+func WithTrustedProxies(matcher RequestMatcher) Option {
+	return func(r *Router) error {
+		r.TrustedProxies = matcher
+		return nil
+	}
+}
+
+// RegisterDefault registers the router to handle all paths on http.DefaultServeMux
+// Only one router in a process may do this, later registrations return an error
+func RegisterDefault() Option {
+	return func(r *Router) (err error) {
+		// http.Handle panics if a handler is already registered for the path
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("Router error: registering default handler failed: %v", p)
+			}
+		}()
+		http.Handle("/", r)
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultParamPattern is the regexp used for params without a pattern, e.g. {name}, matching a single path segment
+const defaultParamPattern = "[^/]+"
+
+// ParamType is a named type for route params, used in patterns as {name:type} instead of a regexp
+type ParamType struct {
+	// The name used in patterns
+	Name string
+
+	// The regexp matching values of this type
+	Pattern string
+
+	// Parse converts a matched value to a typed value, if nil values are strings
+	Parse func(string) (interface{}, error)
+}
+
+// Value returns the typed value for s, or s itself if the type has no Parse function
+func (t *ParamType) Value(s string) (interface{}, error) {
+	if t == nil || t.Parse == nil {
+		return s, nil
+	}
+	return t.Parse(s)
+}
+
+// paramTypes stores the registered param types by name
+var paramTypes = struct {
+	sync.RWMutex
+	types map[string]*ParamType
+}{
+	types: map[string]*ParamType{
+		"int":   {Name: "int", Pattern: "-?[0-9]+", Parse: parseInt},
+		"uint":  {Name: "uint", Pattern: "[0-9]+", Parse: parseUint},
+		"uuid":  {Name: "uuid", Pattern: "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}"},
+		"slug":  {Name: "slug", Pattern: "[a-z0-9]+(?:-[a-z0-9]+)*"},
+		"alpha": {Name: "alpha", Pattern: "[a-zA-Z]+"},
+		"date":  {Name: "date", Pattern: "[0-9]{4}-[0-9]{2}-[0-9]{2}", Parse: parseDate},
+	},
+}
+
+// RegisterParamType adds a param type for use in route patterns as {name:type}
+// Types must be registered before adding routes which use them, and replace any type with the same name
+func RegisterParamType(name string, pattern string, parse func(string) (interface{}, error)) error {
+	if name == "" {
+		return fmt.Errorf("Route error: missing name for param type")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("Route error: invalid pattern for param type %s:%s", name, err)
+	}
+
+	paramTypes.Lock()
+	defer paramTypes.Unlock()
+	paramTypes.types[name] = &ParamType{Name: name, Pattern: pattern, Parse: parse}
+	return nil
+}
+
+// LookupParamType returns the registered param type with this name, or nil if there is none
+func LookupParamType(name string) *ParamType {
+	paramTypes.RLock()
+	defer paramTypes.RUnlock()
+	return paramTypes.types[name]
+}
+
+// routeParam describes a param read from a route pattern
+type routeParam struct {
+	// The name of the param
+	name string
+
+	// The regexp matching values of the param
+	pattern string
+
+	// The registered type of the param, or nil if it uses a regexp
+	paramType *ParamType
+
+	// Whether the param is a catch-all, matching the rest of the path including slashes
+	catchAll bool
+}
+
+// parseParam reads a param in a pattern, in the form name:regexp, name:type, name or name... for a catch-all
+func parseParam(param string) (routeParam, error) {
+	parts := strings.SplitN(param, ":", 2)
+	name := parts[0]
+
+	// Catch-all params match the rest of the path, and take no pattern
+	if strings.HasSuffix(name, "...") {
+		name = strings.TrimSuffix(name, "...")
+		if name == "" || len(parts) == 2 {
+			return routeParam{}, fmt.Errorf("Malformed catch-all param {%s}", param)
+		}
+		return routeParam{name: name, pattern: ".*", catchAll: true}, nil
+	}
+
+	if name == "" {
+		return routeParam{}, fmt.Errorf("Missing name in {%s}", param)
+	}
+
+	// Params with no pattern match a single segment
+	if len(parts) == 1 {
+		return routeParam{name: name, pattern: defaultParamPattern}, nil
+	}
+
+	// Registered type names take precedence over regexps
+	if t := LookupParamType(parts[1]); t != nil {
+		return routeParam{name: name, pattern: t.Pattern, paramType: t}, nil
+	}
+
+	return routeParam{name: name, pattern: parts[1]}, nil
+}
+
+// parseInt parses an int param as an int64
+func parseInt(s string) (interface{}, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseUint parses a uint param as a uint64
+func parseUint(s string) (interface{}, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseDate parses a date param in the form 2006-01-02 as a time.Time
+func parseDate(s string) (interface{}, error) {
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetaErrorFormat is the route metadata key which sets the format for errors from that route, "json" or "html"
+// Without it the format is chosen from the request Accept header
+const MetaErrorFormat = "error.format"
+
+// problemContentType is the content type for RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// WithExtension adds a member to the problem details rendered for this error as JSON, and returns the error
+// Usage: return router.BadRequestError(err).WithExtension("field", "email")
+func (e *StatusError) WithExtension(key string, value interface{}) *StatusError {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]interface{})
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// Problem returns the error as RFC 7807 problem details, with type, title, status, detail and instance members
+// Extensions are added as extra members, and may set type, instance is the path given
+func (e *StatusError) Problem(instance string) map[string]interface{} {
+	problem := make(map[string]interface{}, len(e.Extensions)+5)
+	for k, v := range e.Extensions {
+		problem[k] = v
+	}
+	if _, ok := problem["type"]; !ok {
+		problem["type"] = "about:blank"
+	}
+	problem["title"] = e.Title
+	problem["status"] = e.Status
+	problem["detail"] = e.Message
+	problem["instance"] = instance
+	return problem
+}
+
+// writeProblem writes the error to the context as application/problem+json
+// If NOT in production, the underlying error and its location are added
+func writeProblem(context Context, err *StatusError) {
+	problem := err.Problem(context.Request().URL.Path)
+	if !context.Production() {
+		problem["error"] = fmt.Sprint(err.Err)
+		problem["at"] = err.FileLine()
+	}
+
+	body, e := json.Marshal(problem)
+	if e != nil {
+		context.Logf("#error Encoding problem failed %s", e)
+		body = []byte(`{"type":"about:blank","status":` + strconv.Itoa(err.Status) + `}`)
+	}
+
+	writer := context.Writer()
+	writer.Header().Set("Content-Type", problemContentType)
+	writer.WriteHeader(err.Status)
+	writer.Write(body)
+}
+
+// wantsJSON returns true if errors for this request should be rendered as JSON rather than HTML
+// Route metadata takes precedence, then the Accept header, and HTML is the default
+func wantsJSON(context Context) bool {
+	if route := context.Route(); route != nil {
+		switch route.meta[MetaErrorFormat] {
+		case "json":
+			return true
+		case "html":
+			return false
+		}
+	}
+	return acceptsJSON(context.Request().Header.Get("Accept"))
+}
+
+// acceptsJSON returns true if the Accept header prefers JSON to HTML
+func acceptsJSON(accept string) bool {
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(part)
+		mediaType = strings.ToLower(mediaType)
+
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "*/*":
+			if q > htmlQ {
+				htmlQ = q
+			}
+		}
+	}
+	return jsonQ > htmlQ
+}
+
+// parseMediaRange returns the media type and quality of a media range from an Accept header, e.g. text/html;q=0.5
+func parseMediaRange(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	q := 1.0
+	for _, f := range fields[1:] {
+		f = strings.TrimSpace(f)
+		if strings.HasPrefix(f, "q=") {
+			if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+				q = v
+			}
+		}
+	}
+	return strings.TrimSpace(fields[0]), q
+}
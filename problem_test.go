@@ -0,0 +1,126 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAcceptsJSON checks JSON is chosen only when preferred to HTML
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"APPLICATION/JSON", true},
+		{"text/html", false},
+		{"*/*", false},
+		{"text/html,application/json", false},
+		{"application/json, text/html", false},
+		{"text/html;q=0.9, application/json", true},
+		{"application/json;q=0.5, */*;q=0.1", true},
+		{"application/json;q=0.5, text/html;q=0.5", false},
+		{"application/json;q=0", false},
+		{"text/plain", false},
+		{"text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8", false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsJSON(tt.accept); got != tt.want {
+			t.Errorf("%q: got %t want %t", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// TestProblemResponse checks errors are rendered as problem details for JSON clients and routes set to json
+func TestProblemResponse(t *testing.T) {
+	for _, production := range []bool{false, true} {
+		r, err := New(testLogger{}, testConfig{production: production})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fail := func(c Context) error {
+			return BadRequestError(errors.New("invalid email")).WithExtension("field", "email")
+		}
+		r.Add("/users", fail)
+		r.Add("/api/users", fail).Meta(MetaErrorFormat, "json")
+		r.Add("/html/users", fail).Meta(MetaErrorFormat, "html")
+
+		tests := []struct {
+			path   string
+			accept string
+			json   bool
+		}{
+			{"/users", "", false},
+			{"/users", "text/html", false},
+			{"/users", "application/json", true},
+			{"/api/users", "", true},
+			{"/api/users", "text/html", true},
+			{"/html/users", "application/json", false},
+		}
+
+		for _, tt := range tests {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				request.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: status %d", tt.path, tt.accept, w.Code)
+			}
+			if !tt.json {
+				if w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+					t.Errorf("%s %s: content type %s", tt.path, tt.accept, w.Header().Get("Content-Type"))
+				}
+				continue
+			}
+
+			if w.Header().Get("Content-Type") != problemContentType {
+				t.Errorf("%s %s: content type %s", tt.path, tt.accept, w.Header().Get("Content-Type"))
+			}
+			var problem map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("%s: invalid JSON %s", tt.path, err)
+			}
+			want := map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Bad Request",
+				"status":   float64(http.StatusBadRequest),
+				"detail":   "Sorry, there was an error processing your request, please check your data.",
+				"instance": tt.path,
+				"field":    "email",
+			}
+			for k, v := range want {
+				if problem[k] != v {
+					t.Errorf("%s: %s got %v want %v", tt.path, k, problem[k], v)
+				}
+			}
+
+			// The underlying error and location are only shown outside production
+			at, _ := problem["at"].(string)
+			if production {
+				if problem["error"] != nil || problem["at"] != nil {
+					t.Errorf("%s: error shown in production %v", tt.path, problem)
+				}
+			} else if problem["error"] != "invalid email" || !strings.Contains(at, "problem_test.go:") {
+				t.Errorf("%s: error not shown %v", tt.path, problem)
+			}
+		}
+	}
+}
+
+// TestProblemType checks extensions may set the problem type
+func TestProblemType(t *testing.T) {
+	problem := NotFoundError(nil).WithExtension("type", "https://example.com/not-found").WithExtension("title", "ignored").Problem("/x")
+	if problem["type"] != "https://example.com/not-found" || problem["title"] != "Not Found" || problem["instance"] != "/x" {
+		t.Errorf("problem wrong: %v", problem)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -25,6 +26,12 @@ type Route struct {
 	// Param names taken from the Pattern and matching params
 	ParamNames []string
 
+	// Regexps matching the whole value of each param in ParamNames, used to validate URL params
+	paramRegexps []*regexp.Regexp
+
+	// The registered type of each param in ParamNames, or nil for params using a regexp
+	paramTypes []*ParamType
+
 	// Redirect path - used to redirect if handler is nil
 	RedirectPath string
 
@@ -33,6 +40,42 @@ type Route struct {
 
 	// Permitted HTTP methods (GET, POST) - default GET
 	methods []string
+
+	// The name used to find this route when generating URLs
+	name string
+
+	// The group this route was added to, if any
+	group *Group
+
+	// A list of filters applied before the handler for this route only
+	filters []Handler
+
+	// A list of filters applied after the handler for this route only
+	afterFilters []Handler
+
+	// Whether the router answers OPTIONS requests for this route - default true
+	options bool
+
+	// The host pattern this route is restricted to, if any
+	host string
+
+	// The matcher compiled from the host pattern
+	hostMatcher *valueMatcher
+
+	// Header values required to match this route
+	headers []*valueMatcher
+
+	// Query values required to match this route
+	queries []*valueMatcher
+
+	// Schemes required to match this route, empty for any scheme
+	schemes []string
+
+	// The first error from setting up this route after it was created
+	err error
+
+	// Arbitrary metadata describing this route, for use by tools such as documentation generators
+	meta map[string]interface{}
 }
 
 // NewRoute creates a new Route, given a pattern to match and a handler for the route
@@ -43,6 +86,7 @@ func NewRoute(pattern string, handler Handler) (*Route, error) {
 		Pattern:      pattern,
 		PatternShort: shortPattern(pattern),
 		methods:      []string{http.MethodGet, http.MethodHead}, // Allow GET and HEAD by default
+		options:      true,
 	}
 
 	// Check for regexps within pattern and parse if necessary
@@ -98,6 +142,95 @@ func (r *Route) Methods(permitted ...string) *Route {
 	return r
 }
 
+// Filter adds filters to execute before the handler when this route is selected
+// These run after the router and group filters
+func (r *Route) Filter(handlers ...Handler) *Route {
+	r.filters = append(r.filters, handlers...)
+	return r
+}
+
+// After adds filters to execute after the handler when this route is selected
+// These run only if the handler returns no error
+func (r *Route) After(handlers ...Handler) *Route {
+	r.afterFilters = append(r.afterFilters, handlers...)
+	return r
+}
+
+// filterChain returns the filters to execute before the handler, starting with those of the route group
+func (r *Route) filterChain() []Handler {
+	return append(r.group.chain(), r.filters...)
+}
+
+// DisableOptions stops the router answering OPTIONS requests for this route automatically
+// OPTIONS requests for the path then go to other routes or the FileHandler, usually a 404, unless the route accepts OPTIONS
+func (r *Route) DisableOptions() *Route {
+	r.options = false
+	return r
+}
+
+// Meta stores metadata describing this route under key, for use by tools such as documentation generators
+func (r *Route) Meta(key string, value interface{}) *Route {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = value
+	return r
+}
+
+// AllowedMethods returns a copy of the HTTP methods this route accepts
+func (r *Route) AllowedMethods() []string {
+	return append([]string{}, r.methods...)
+}
+
+// Name sets the name used to find this route when generating URLs
+func (r *Route) Name(name string) *Route {
+	r.name = name
+	return r
+}
+
+// URL returns the path for this route with the given params substituted into the pattern
+// Params are given as key value pairs, e.g. URL("id", "3") and each value must match the regexp for its param
+func (r *Route) URL(pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("Route error: odd number of params for route %s", r.Pattern)
+	}
+
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+
+	// Routes without params are a simple string
+	if r.Regexp == nil {
+		return r.Pattern, nil
+	}
+
+	idxs, err := r.findBraces(r.Pattern)
+	if err != nil {
+		return "", err
+	}
+
+	result := bytes.NewBufferString("")
+	end := 0
+	for i := 0; i < len(idxs); i += 2 {
+		result.WriteString(r.Pattern[end:idxs[i]])
+		end = idxs[i+1]
+
+		key := r.ParamNames[i/2]
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("Route error: missing param %s for route %s", key, r.Pattern)
+		}
+		if !r.paramRegexps[i/2].MatchString(value) {
+			return "", fmt.Errorf("Route error: invalid param %s:%q for route %s", key, value, r.Pattern)
+		}
+		result.WriteString(escapePath(value))
+	}
+	result.WriteString(r.Pattern[end:])
+
+	return result.String(), nil
+}
+
 // Parse reads our params using the regexp from the given path
 func (r *Route) Parse(path string) map[string]string {
 
@@ -129,6 +262,32 @@ func (r *Route) Parse(path string) map[string]string {
 	return params
 }
 
+// ParamType returns the registered type of the param key, or nil if it uses a regexp or is not a param of this route
+func (r *Route) ParamType(key string) *ParamType {
+	if r == nil {
+		return nil
+	}
+	for i, name := range r.ParamNames {
+		if name == key {
+			return r.paramTypes[i]
+		}
+	}
+	return nil
+}
+
+// ParseValues reads our params from the given path, converting params with a registered type to typed values
+func (r *Route) ParseValues(path string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for key, param := range r.Parse(path) {
+		value, err := r.ParamType(key).Value(param)
+		if err != nil {
+			return nil, fmt.Errorf("Route error: invalid param %s:%q for route %s", key, param, r.Pattern)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
 // MatchMethod returns true if our list of methods contains method
 func (r *Route) MatchMethod(method string) bool {
 
@@ -149,11 +308,6 @@ func (r *Route) MatchMethod(method string) bool {
 // MatchPath returns true if this route matches the path
 func (r *Route) MatchPath(path string) bool {
 
-	// Reject asset paths, which we don't handle (server should be handling)
-	if strings.HasPrefix(path, "/assets") {
-		return false
-	}
-
 	// Check against short pattern first, to reject obvious misses
 	if len(r.PatternShort) > 0 {
 		if !strings.HasPrefix(path, r.PatternShort) {
@@ -178,7 +332,8 @@ func (r *Route) MatchPath(path string) bool {
 }
 
 // compileRegexp compiles our route format to a true regexp
-// Both name and regexp are required - routes should be well structured and restrictive by default
+// Params may use a regexp, a registered type such as {id:int}, or only a name to match a single segment
+// A catch-all param such as {path...} matches the rest of the path including slashes, and must end the pattern
 // Convert the pattern from the form  /pages/{id:[0-9]*}/edit?param=test
 // to one suitable for regexp -  /pages/([0-9]*)/edit\?param=test
 // We want to match things like this:
@@ -199,16 +354,37 @@ func (r *Route) compileRegexp() (err error) {
 		// Set all values we are interested in.
 		raw := r.Pattern[end:idxs[i]]
 		end = idxs[i+1]
-		parts := strings.SplitN(r.Pattern[idxs[i]+1:end-1], ":", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("Missing name or pattern in %s", raw)
+		param, errParam := parseParam(r.Pattern[idxs[i]+1 : end-1])
+		if errParam != nil {
+			return errParam
 		}
 
-		// Add the Argument name
-		r.ParamNames = append(r.ParamNames, parts[0])
+		// Add the Argument name and type
+		r.ParamNames = append(r.ParamNames, param.name)
+		r.paramTypes = append(r.paramTypes, param.paramType)
+
+		// Add a regexp to validate values for this param
+		paramRegexp, errParam := regexp.Compile("^(?:" + param.pattern + ")$")
+		if errParam != nil {
+			return errParam
+		}
+		r.paramRegexps = append(r.paramRegexps, paramRegexp)
+
+		// Catch-alls must end the pattern, and also match the path without a trailing slash
+		if param.catchAll {
+			if end != len(r.Pattern) {
+				return fmt.Errorf("Route error: catch-all param %s must end the pattern %q", param.name, r.Pattern)
+			}
+			if strings.HasSuffix(raw, "/") {
+				fmt.Fprintf(pattern, "%s(?:/(.*))?$", regexp.QuoteMeta(strings.TrimSuffix(raw, "/")))
+			} else {
+				fmt.Fprintf(pattern, "%s(.*)$", regexp.QuoteMeta(raw))
+			}
+			break
+		}
 
 		// Add the real regexp
-		fmt.Fprintf(pattern, "%s(%s)", regexp.QuoteMeta(raw), parts[1])
+		fmt.Fprintf(pattern, "%s(%s)", regexp.QuoteMeta(raw), param.pattern)
 
 	}
 	// Add the remaining pattern
@@ -260,7 +436,17 @@ func shortPattern(p string) string {
 	return p[:l]
 }
 
+// escapePath escapes a param value for use in a path, leaving slashes intact
+func escapePath(value string) string {
+	return strings.Replace(url.PathEscape(value), "%2F", "/", -1)
+}
+
+// Err returns the first error from setting up this route after it was created, for example an invalid host
+func (r *Route) Err() error {
+	return r.err
+}
+
 // String returns the route formatted as a string
 func (r *Route) String() string {
-	return fmt.Sprintf("%s %s", r.methods, r.Pattern)
+	return fmt.Sprintf("%s %s%s", r.methods, r.host, r.Pattern)
 }
@@ -3,10 +3,12 @@ package router
 
 import (
 	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,9 @@ type Handler func(Context) error
 // ErrHandler is used to render a router.Error - used by ErrorHandler on the router
 type ErrHandler func(Context, error)
 
+// Middleware wraps an http.Handler in another, in the style of standard net/http middleware
+type Middleware func(http.Handler) http.Handler
+
 // Logger Interface for a simple logger (the stdlib log pkg and the fragmenta log pkg conform)
 type Logger interface {
 	Printf(format string, args ...interface{})
@@ -29,6 +34,34 @@ type Config interface {
 	Config(string) string
 }
 
+// RouteError records a route pattern which could not be added to the router
+type RouteError struct {
+	Pattern string
+	Err     error
+}
+
+// Error returns the pattern and the reason it could not be added
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("Route error: invalid pattern %s:%s", e.Pattern, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// RouteErrors lists every route pattern which could not be added to the router
+type RouteErrors []*RouteError
+
+// Error returns the errors for each pattern, one per line
+func (e RouteErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Router stores and handles the routes
 type Router struct {
 	// Mutex protects routes and filters
@@ -40,30 +73,65 @@ type Router struct {
 	// Error handler (renders errors)
 	ErrorHandler ErrHandler
 
+	// Options handler (responds to OPTIONS requests for routes, after the Allow header is set)
+	OptionsHandler Handler
+
 	// The logger passed to actions within the context on each request
 	Logger Logger
 
 	// The server config passed to actions within the context on each request
 	Config Config
 
+	// Requests matching SkipLogging are not logged - by default paths starting with /assets or /files
+	SkipLogging RequestMatcher
+
+	// Requests matching SkipRouting are not matched against routes and go to FileHandler - by default paths starting with /assets
+	SkipRouting RequestMatcher
+
+	// Requests matching TrustedProxies are from proxies whose X-Forwarded-Proto header is used for route schemes - by default none
+	TrustedProxies RequestMatcher
+
 	// A list of routes
 	routes []*Route
 
+	// A list of errors for patterns which could not be added
+	errors []*RouteError
+
+	// A prefix tree indexing routes by pattern, used to find routes quickly
+	tree *node
+
 	// A list of pre-action filters, applied before any handler
 	filters []Handler
+
+	// A list of middleware wrapping the router dispatch, outermost first
+	middleware []Middleware
+
+	// The dispatch wrapped in all middleware, nil if there is no middleware
+	handler http.Handler
 }
 
-// New creates a new router
-func New(l Logger, s Config) (*Router, error) {
+// New creates a new router configured by any options given
+// The router is a standalone http.Handler, use the RegisterDefault option to handle all paths on http.DefaultServeMux
+func New(l Logger, s Config, options ...Option) (*Router, error) {
 	r := &Router{
-		FileHandler:  fileHandler,
-		ErrorHandler: errHandler,
-		Logger:       l,
-		Config:       s,
+		FileHandler:    fileHandler,
+		ErrorHandler:   errHandler,
+		OptionsHandler: optionsHandler,
+		Logger:         l,
+		Config:         s,
+		SkipLogging:    MatchPrefixes("/assets", "/files"),
+		SkipRouting:    MatchPrefixes("/assets"),
+		tree:           &node{},
+	}
+
+	// Apply any options in order
+	for _, option := range options {
+		err := option(r)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Set our router to handle all routes
-	http.Handle("/", r)
 	return r, nil
 }
 
@@ -78,6 +146,8 @@ func (r *Router) Log(message string) {
 }
 
 // Add a new route
+// Where more than one route matches a request, the route added first takes priority
+// If the pattern is invalid the error is logged and kept for Err, and the route returned is not stored
 func (r *Router) Add(pattern string, handler Handler) *Route {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -86,16 +156,31 @@ func (r *Router) Add(pattern string, handler Handler) *Route {
 	route, err := NewRoute(pattern, handler)
 	if err != nil {
 		r.Logf("#error Creating regexp failed for route %s:%s", pattern, err)
+		return r.failedRoute(pattern, handler, err)
 	}
 
 	// Store this route in the router
-	r.routes = append(r.routes, route)
+	r.addRoute(route)
 
 	// Return route for chaining
 	return route
 }
 
+// MustAdd adds a new route, and panics if the pattern is invalid
+func (r *Router) MustAdd(pattern string, handler Handler) *Route {
+	route, err := NewRoute(pattern, handler)
+	if err != nil {
+		panic(&RouteError{Pattern: pattern, Err: err})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addRoute(route)
+	return route
+}
+
 // AddRedirect adds a new redirect this is just a route with a redirect path set
+// If the pattern is invalid the error is logged and kept for Err, and the route returned is not stored
 func (r *Router) AddRedirect(pattern string, redirectPath string, status int) *Route {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -104,17 +189,100 @@ func (r *Router) AddRedirect(pattern string, redirectPath string, status int) *R
 	route, err := NewRoute(pattern, nil)
 	if err != nil {
 		r.Logf("#error Creating redirect failed for route %s:%s", pattern, err)
+		route = r.failedRoute(pattern, nil, err)
+		route.RedirectPath = redirectPath
+		route.RedirectStatus = status
+		return route
 	}
 	route.RedirectPath = redirectPath
 	route.RedirectStatus = status
 
 	// Store this route in the router
-	r.routes = append(r.routes, route)
+	r.addRoute(route)
 
 	// Return route for chaining
 	return route
 }
 
+// Err returns an error listing every pattern which could not be added, or nil if all routes were added
+// Usage: check after adding routes to fail fast at startup
+func (r *Router) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	errs := append(RouteErrors{}, r.errors...)
+
+	// Include errors from setting up stored routes
+	for _, route := range r.routes {
+		if route.err != nil {
+			errs = append(errs, &RouteError{Pattern: route.Pattern, Err: route.err})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// failedRoute records the error for a pattern which could not be added,
+// and returns a route which is not stored, so that chained calls are safe
+func (r *Router) failedRoute(pattern string, handler Handler, err error) *Route {
+	r.errors = append(r.errors, &RouteError{Pattern: pattern, Err: err})
+	return &Route{
+		Handler: handler,
+		Pattern: pattern,
+		methods: []string{http.MethodGet, http.MethodHead},
+		options: true,
+	}
+}
+
+// URL returns the path for the route with this name, with the given params substituted into the pattern
+// Params are given as key value pairs, e.g. router.URL("tag.destroy", "id", "3")
+func (r *Router) URL(name string, pairs ...string) (string, error) {
+	route := r.namedRoute(name)
+	if route == nil {
+		return "", fmt.Errorf("Route error: no route named %s", name)
+	}
+	return route.URL(pairs...)
+}
+
+// FuncMap returns template functions for generating URLs from named routes
+// Usage in templates: {{ url "tag.destroy" "id" .ID }}
+func (r *Router) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"url": func(name string, pairs ...interface{}) (string, error) {
+			params := make([]string, len(pairs))
+			for i, p := range pairs {
+				params[i] = fmt.Sprint(p)
+			}
+			return r.URL(name, params...)
+		},
+	}
+}
+
+// namedRoute returns the first route added with this name, or nil if there is none
+func (r *Router) namedRoute(name string) *Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, route := range r.routes {
+		if route.name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// addRoute stores the route in our list of routes and indexes it in our tree
+func (r *Router) addRoute(route *Route) {
+	if r.tree == nil {
+		r.tree = &node{}
+	}
+	r.tree.insert(route, len(r.routes))
+	r.routes = append(r.routes, route)
+}
+
 // AddFilter adds a new filter to our list of filters to execute before request handlers
 func (r *Router) AddFilter(filter Handler) {
 	r.mu.Lock()
@@ -142,8 +310,37 @@ func (r *Router) AddFilterHandlerFunc(handler http.HandlerFunc) {
 	r.AddFilter(f)
 }
 
-// ServeHTTP - Central dispatcher for web requests - sets up the context and hands off to handlers
+// Use adds middleware wrapping the router dispatch, including creation of the context
+// Middleware added first is outermost, so it sees the request before later middleware
+func (r *Router) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+
+	// Rebuild the handler, wrapping dispatch in middleware from the innermost out
+	var handler http.Handler = http.HandlerFunc(r.dispatch)
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	r.handler = handler
+}
+
+// ServeHTTP - Central dispatcher for web requests - passes the request through any middleware to dispatch
 func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	r.mu.RLock()
+	handler := r.handler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		handler.ServeHTTP(writer, request)
+		return
+	}
+
+	r.dispatch(writer, request)
+}
+
+// dispatch sets up the context and hands off to handlers
+func (r *Router) dispatch(writer http.ResponseWriter, request *http.Request) {
 
 	// Lock handlers/filters for duration of handling
 	r.mu.RLock()
@@ -154,24 +351,30 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	summary := fmt.Sprintf("%s %s for %s", request.Method, request.URL.Path, remoteIP(request))
 
 	// Clean the path
-	canonicalPath := path.Clean(request.URL.Path)
-	if len(canonicalPath) == 0 {
-		canonicalPath = "/"
-	} else if canonicalPath[0] != '/' {
-		canonicalPath = "/" + canonicalPath
-	}
+	canonicalPath := cleanPath(request.URL.Path)
 
-	status := 200
+	// Record the status and bytes written for every response
+	recorder := newRecordingWriter(writer, started)
+	writer = recorder
 
-	// Log starting the request
-	// FIXME: We should have some way of excluding logs not hard-coded like this
-	logging := !strings.HasPrefix(canonicalPath, "/assets") && !strings.HasPrefix(canonicalPath, "/files")
+	// Log starting the request, unless excluded
+	logging := !r.SkipLogging.matches(request)
 	if logging {
 		r.Logf("#info Started %s", summary)
+
+		// Log the end of handling, whichever way the request is handled
+		defer func() {
+			end := time.Since(started).String()
+			r.Logf("#info Finished %s status %d (%d bytes) in %s", summary, recorder.Status(), recorder.bytes, end)
+		}()
 	}
 
-	// Try finding a route
-	route := r.findRoute(canonicalPath, request)
+	// Try finding a route, unless the request should skip routing
+	var route *Route
+	var allowed []string
+	if !r.SkipRouting.matches(request) {
+		route, allowed = r.findRoute(canonicalPath, request)
+	}
 
 	// Our handler may end as nil
 	var handler Handler
@@ -193,7 +396,7 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 
 	// Setup the context
 	context := &ConcreteContext{
-		writer:  writer,
+		writer:  recorder,
 		request: request,
 		path:    canonicalPath,
 		route:   route,
@@ -202,6 +405,24 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		data:    make(map[string]interface{}, 0),
 	}
 
+	// Recover from panics in filters and handlers, log them and render them as errors
+	defer func() {
+		if p := recover(); p != nil {
+			// Let net/http handle deliberate aborts
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			err := PanicError(p)
+			r.Logf("#error Panic handling %s: %v\n%s", summary, p, err.Stack)
+
+			// If the response has been started, rendering the error would mix it with the response
+			if recorder.status != 0 {
+				return
+			}
+			r.ErrorHandler(context, err)
+		}
+	}()
+
 	// Call any filters
 	for _, f := range r.filters {
 		err := f(context)
@@ -211,6 +432,17 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		}
 	}
 
+	// Call any filters for the route and its group
+	if route != nil {
+		for _, f := range route.filterChain() {
+			err := f(context)
+			if err != nil {
+				r.ErrorHandler(context, err)
+				return
+			}
+		}
+	}
+
 	// If handler is not nil, serve, else fall back to defaults
 	if handler != nil {
 
@@ -221,15 +453,34 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 			return
 		}
 
-		// Log the end of handling
-		end := time.Since(started).String()
+		// Call any filters for the route after the handler
+		for _, f := range route.afterFilters {
+			err := f(context)
+			if err != nil {
+				r.ErrorHandler(context, err)
+				return
+			}
+		}
 
-		if logging {
-			r.Logf("#info Finished %s status %d in %s", summary, status, end)
+	} else if len(allowed) > 0 && request.Method == http.MethodOptions {
+		// If routes match the path, answer OPTIONS requests with the methods they allow
+		writer.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		err := r.OptionsHandler(context)
+		if err != nil {
+			r.ErrorHandler(context, err)
+			return
 		}
 
+	} else if len(allowed) > 0 {
+		// If routes match the path but not the method, render method not allowed
+		allow := strings.Join(allowed, ", ")
+		writer.Header().Set("Allow", allow)
+		err := fmt.Errorf("Method %s not allowed for %s", request.Method, canonicalPath)
+		r.ErrorHandler(context, MethodNotAllowedError(err).WithHeader("Allow", allow))
+		return
+
 	} else {
-		// If no route or handler, try default file handler to serve static files (no logging)
+		// If no route or handler, try default file handler to serve static files
 		err := r.FileHandler(context)
 		if err != nil {
 			r.ErrorHandler(context, err)
@@ -240,17 +491,43 @@ func (r *Router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 }
 
 // findRoute finds the matching route given a cleaned path - this may return nil
-func (r *Router) findRoute(canonicalPath string, request *http.Request) *Route {
+// The tree narrows down the routes to check, and the first route added which matches is returned
+// If routes match the path but not the method, it returns nil and the methods those routes allow
+// For OPTIONS requests, routes which have disabled automatic options are ignored
+func (r *Router) findRoute(canonicalPath string, request *http.Request) (*Route, []string) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	for _, r := range r.routes {
-		// Check method (GET/PUT), then check path
-		if r.MatchMethod(request.Method) && r.MatchPath(canonicalPath) {
-			return r
+	if r.tree == nil {
+		return nil, nil
+	}
+
+	// Check candidates in the order they were added
+	candidates := r.tree.match(canonicalPath, nil)
+	sort.Ints(candidates)
+
+	scheme := requestScheme(request, r.TrustedProxies.matches(request))
+
+	var allowed []string
+	for _, i := range candidates {
+		route := r.routes[i]
+		// Check path and other constraints, then check method (GET/PUT)
+		if !route.MatchPath(canonicalPath) || !route.matchRequest(request, scheme) {
+			continue
+		}
+		if route.MatchMethod(request.Method) {
+			return route, nil
+		}
+		if request.Method == http.MethodOptions && !route.options {
+			continue
+		}
+		for _, m := range route.methods {
+			if !containsString(allowed, m) {
+				allowed = append(allowed, m)
+			}
 		}
 	}
-	return nil
+	return nil, allowed
 }
 
 // fileHandler is the default static file handler - this is the last line of handlers
@@ -277,11 +554,32 @@ func fileHandler(context Context) error {
 	return nil
 }
 
+// optionsHandler is the default handler for OPTIONS requests - it responds with no content
+func optionsHandler(context Context) error {
+	context.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 // errHandler is a simple error handler which writes the error to context.Writer
+// Errors are written as application/problem+json for clients which prefer JSON, and as html otherwise
 func errHandler(context Context, e error) {
 
 	// Cast the error to a status error if it is one, if not wrap it in a Status 500 error
 	err := ToStatusError(e)
+	context.Logf("#error %s\n", err)
+	err.writeHeaders(context.Writer().Header())
+
+	// Write problem details for JSON clients
+	if wantsJSON(context) {
+		writeProblem(context, err)
+		return
+	}
+
+	writeErrorHTML(context, err)
+}
+
+// writeErrorHTML writes a simple html error page for the error to context.Writer
+func writeErrorHTML(context Context, err *StatusError) {
 
 	// Get the writer from context and write the error page
 	writer := context.Writer()
@@ -293,13 +591,15 @@ func errHandler(context Context, e error) {
 	// Write a simple error message page
 	html := fmt.Sprintf("<h1>%s</h1><p>%s</p>", err.Title, err.Message)
 
-	// If NOT in production, write a more complex page which reveals the real error (later stack trace etc)
+	// If NOT in production, write a more complex page which reveals the real error and any stack trace
 	if !context.Production() {
 		html = fmt.Sprintf("<h1>%s</h1><p>%s</p><p>Error %d at %s</p><p><code>Error:%s</code></p>",
-			err.Title, err.Message, err.Status, err.FileLine(), err.Err.Error())
+			err.Title, err.Message, err.Status, err.FileLine(), err.Err)
+		if len(err.Stack) > 0 {
+			html += fmt.Sprintf("<pre>%s</pre>", template.HTMLEscapeString(string(err.Stack)))
+		}
 	}
 
-	context.Logf("#error %s\n", err)
 	io.WriteString(writer, html)
 }
 
@@ -0,0 +1,414 @@
+package router
+
+import (
+	"fmt"
+	"html/template"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testLogger discards log output
+type testLogger struct{}
+
+func (testLogger) Printf(format string, args ...interface{}) {}
+
+// testConfig is the server config used by test routers
+type testConfig struct {
+	production bool
+}
+
+func (c testConfig) Production() bool         { return c.production }
+func (c testConfig) Config(key string) string { return "" }
+
+// newTestRouter returns a router for tests, it fails the test on error
+func newTestRouter(t testing.TB, options ...Option) *Router {
+	r, err := New(testLogger{}, testConfig{}, options...)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	return r
+}
+
+// serve sends a request for method and url to the handler and returns the response
+func serve(h http.Handler, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+// testHandler is a handler which does nothing
+func testHandler(context Context) error {
+	return nil
+}
+
+// findRouteLinear is the matcher used before the prefix tree, it checks every route in order
+func findRouteLinear(r *Router, canonicalPath string, request *http.Request) *Route {
+	for _, route := range r.routes {
+		if route.MatchPath(canonicalPath) && route.MatchRequest(request) && route.MatchMethod(request.Method) {
+			return route
+		}
+	}
+	return nil
+}
+
+// routeTablePatterns are patterns covering static routes, typed, bare and catch-all params and regexps
+var routeTablePatterns = []string{
+	"/",
+	"/users",
+	"/users/create",
+	"/users/{id:int}",
+	"/users/{id:int}/edit",
+	"/users/{id:[0-9]+}-{slug:[a-z]+}",
+	"/users/{name:[a-z]+}",
+	"/users/{id:[a-z0-9]+}/update",
+	"/uploads/{name}",
+	"/pages/about",
+	"/pages/{path:.*}",
+	"/a{x:[a-z]}",
+	"/files/{id:[^x]+}/y",
+	"/docs/{path...}",
+	"/blog/{year:int}/{month:int}/{slug:slug}",
+	"/{all:.*}",
+}
+
+// routeTablePaths are paths to match against routeTablePatterns
+var routeTablePaths = []string{
+	"/", "/users", "/users/", "/users/1", "/users/12/edit", "/users/12/editor", "/users/abc", "/users/1-ab",
+	"/users/create", "/users/1a/update", "/users/12abc", "/uploads/a.png", "/uploads/", "/pages/x/y",
+	"/pages/about", "/ab", "/a", "/files/a/b/y", "/files/ab/y", "/docs", "/docs/", "/docs/a/b",
+	"/blog/2024/01/hello", "/blog/2024/jan/hello", "/zzz",
+}
+
+// TestFindRouteLinear checks the tree finds the same route as a linear scan, for every order of the routes
+func TestFindRouteLinear(t *testing.T) {
+	for offset := range routeTablePatterns {
+		r := newTestRouter(t)
+		for i := range routeTablePatterns {
+			r.Add(routeTablePatterns[(i+offset)%len(routeTablePatterns)], testHandler)
+		}
+
+		for _, p := range routeTablePaths {
+			request := httptest.NewRequest(http.MethodGet, p, nil)
+			want := findRouteLinear(r, p, request)
+			got, _ := r.findRoute(p, request)
+			if got != want {
+				t.Errorf("offset %d path %s: got %v want %v", offset, p, got, want)
+			}
+		}
+	}
+}
+
+// TestFindRouteRandom checks the tree against a linear scan for random paths built from route segments
+func TestFindRouteRandom(t *testing.T) {
+	r := newTestRouter(t)
+	for _, p := range routeTablePatterns {
+		r.Add(p, testHandler)
+	}
+
+	segments := []string{"users", "create", "edit", "update", "pages", "about", "docs", "files", "uploads",
+		"blog", "1", "12", "2024", "abc", "1-ab", "a", "ab", "x", "y", "hello", "1a", ""}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		parts := make([]string, random.Intn(5))
+		for j := range parts {
+			parts[j] = segments[random.Intn(len(segments))]
+		}
+		p := "/" + strings.Join(parts, "/")
+
+		request := httptest.NewRequest(http.MethodGet, p, nil)
+		want := findRouteLinear(r, p, request)
+		got, _ := r.findRoute(p, request)
+		if got != want {
+			t.Errorf("path %s: got %v want %v", p, got, want)
+		}
+	}
+}
+
+// benchmarkRouter returns a router with several hundred routes, like a large app
+func benchmarkRouter(b *testing.B) *Router {
+	r := newTestRouter(b)
+	for i := 0; i < 100; i++ {
+		r.Add(fmt.Sprintf("/resources%d", i), testHandler)
+		r.Add(fmt.Sprintf("/resources%d/create", i), testHandler)
+		r.Add(fmt.Sprintf("/resources%d/{id:int}/update", i), testHandler).Post()
+		r.Add(fmt.Sprintf("/resources%d/{id:int}/edit", i), testHandler)
+		r.Add(fmt.Sprintf("/resources%d/{id:int}", i), testHandler)
+	}
+	return r
+}
+
+// benchmarkPaths are paths matching routes early, late and not at all in benchmarkRouter
+var benchmarkPaths = []string{"/resources0", "/resources50/create", "/resources99/12/edit", "/resources99/12", "/missing/path"}
+
+// BenchmarkFindRoute measures finding routes with the prefix tree
+func BenchmarkFindRoute(b *testing.B) {
+	r := benchmarkRouter(b)
+	for _, p := range benchmarkPaths {
+		request := httptest.NewRequest(http.MethodGet, p, nil)
+		b.Run(p, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r.findRoute(p, request)
+			}
+		})
+	}
+}
+
+// BenchmarkFindRouteLinear measures finding routes with a linear scan, for comparison with BenchmarkFindRoute
+func BenchmarkFindRouteLinear(b *testing.B) {
+	r := benchmarkRouter(b)
+	for _, p := range benchmarkPaths {
+		request := httptest.NewRequest(http.MethodGet, p, nil)
+		b.Run(p, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findRouteLinear(r, p, request)
+			}
+		})
+	}
+}
+
+// TestDispatchPanic checks panics are rendered as errors, with the stack only when not in production
+func TestDispatchPanic(t *testing.T) {
+	for _, production := range []bool{false, true} {
+		r, err := New(testLogger{}, testConfig{production: production})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Add("/panic", func(c Context) error {
+			panic("broken")
+		})
+		r.AddFilter(func(c Context) error {
+			if c.Path() == "/filter" {
+				panic("broken filter")
+			}
+			return nil
+		})
+
+		for _, p := range []string{"/panic", "/filter"} {
+			w := serve(r, http.MethodGet, p)
+			body := w.Body.String()
+			if w.Code != http.StatusInternalServerError || !strings.Contains(body, "<h1>Server Error</h1>") {
+				t.Errorf("production %t %s: got %d %s", production, p, w.Code, body)
+			}
+			hasStack := strings.Contains(body, "<pre>") && strings.Contains(body, "router_test.go")
+			if hasStack == production {
+				t.Errorf("production %t %s: stack shown %t", production, p, hasStack)
+			}
+		}
+	}
+}
+
+// TestDispatchPanicWritten checks panics after the response has started are not rendered
+func TestDispatchPanicWritten(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/written", func(c Context) error {
+		c.Writer().WriteHeader(http.StatusAccepted)
+		c.Writer().Write([]byte("started"))
+		panic("broken")
+	})
+	r.Add("/teapot", func(c Context) error {
+		return &StatusError{Status: http.StatusTeapot, Title: "Teapot"}
+	})
+
+	if w := serve(r, http.MethodGet, "/written"); w.Code != http.StatusAccepted || w.Body.String() != "started" {
+		t.Errorf("panic after write rendered: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(r, http.MethodGet, "/teapot"); w.Code != http.StatusTeapot || !strings.HasPrefix(w.Body.String(), "<h1>Teapot</h1>") || strings.Contains(w.Body.String(), "Server Error") {
+		t.Errorf("error without Err rendered wrongly: %d %s", w.Code, w.Body.String())
+	}
+
+	// An error handler which panics part way through is not run again
+	r.ErrorHandler = func(c Context, e error) {
+		c.Writer().WriteHeader(ToStatusError(e).Status)
+		panic("broken error handler")
+	}
+	if w := serve(r, http.MethodGet, "/teapot"); w.Code != http.StatusTeapot || w.Body.Len() != 0 {
+		t.Errorf("panic in error handler rendered: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// TestURL checks URLs generated for named routes
+func TestURL(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/tags", testHandler).Name("tags")
+	r.Add("/tags/{id:int}/update", testHandler).Name("tag.update")
+	r.Add("/pages/{name}", testHandler).Name("page")
+	r.Add("/docs/{path...}", testHandler).Name("docs")
+	r.Add("/tags/{id:int}", testHandler).Name("tags")
+
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+		err   bool
+	}{
+		{"tags", nil, "/tags", false},
+		{"tags", []string{"id", "3"}, "/tags", false},
+		{"tag.update", []string{"id", "3"}, "/tags/3/update", false},
+		{"tag.update", []string{"id", "-3", "other", "x"}, "/tags/-3/update", false},
+		{"tag.update", nil, "", true},
+		{"tag.update", []string{"id", "abc"}, "", true},
+		{"tag.update", []string{"id"}, "", true},
+		{"page", []string{"name", "a b"}, "/pages/a%20b", false},
+		{"page", []string{"name", "a/b"}, "", true},
+		{"docs", []string{"path", "a b/c.html"}, "/docs/a%20b/c.html", false},
+		{"docs", []string{"path", ""}, "/docs/", false},
+		{"missing", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := r.URL(tt.name, tt.pairs...)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%s %v: got %q %v want %q error %t", tt.name, tt.pairs, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// TestFuncMap checks the url template function
+func TestFuncMap(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/tags/{id:int}/update", testHandler).Name("tag.update")
+
+	tmpl := template.Must(template.New("test").Funcs(r.FuncMap()).Parse(`{{ url "tag.update" "id" .ID }}`))
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, struct{ ID int64 }{3}); err != nil || b.String() != "/tags/3/update" {
+		t.Errorf("url got %q %v", b.String(), err)
+	}
+
+	tmpl = template.Must(template.New("test").Funcs(r.FuncMap()).Parse(`{{ url "missing" }}`))
+	if err := tmpl.Execute(&strings.Builder{}, nil); err == nil {
+		t.Errorf("url for missing route did not fail")
+	}
+}
+
+// TestOptions checks OPTIONS requests are answered with the methods allowed by matching routes
+func TestOptions(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/users", testHandler)
+	r.Add("/users", testHandler).Post()
+	r.Add("/users/{id:int}", testHandler).Methods("PUT", "DELETE")
+	r.Add("/private", testHandler).DisableOptions()
+	r.Add("/custom", testHandler).DisableOptions().Methods("GET", "OPTIONS")
+
+	tests := []struct {
+		path   string
+		status int
+		allow  string
+	}{
+		{"/users", http.StatusNoContent, "GET, HEAD, POST, OPTIONS"},
+		{"/users/1", http.StatusNoContent, "PUT, DELETE, OPTIONS"},
+		{"/private", http.StatusNotFound, ""},
+		{"/custom", http.StatusOK, ""},
+		{"/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := serve(r, http.MethodOptions, tt.path)
+		if w.Code != tt.status || w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s: got %d %q want %d %q", tt.path, w.Code, w.Header().Get("Allow"), tt.status, tt.allow)
+		}
+	}
+
+	// OptionsHandler may add to the response, e.g. for CORS
+	r.OptionsHandler = func(c Context) error {
+		c.Writer().Header().Set("Access-Control-Allow-Methods", c.Writer().Header().Get("Allow"))
+		c.WriteHeader(http.StatusOK)
+		return nil
+	}
+	w := serve(r, http.MethodOptions, "/users/1")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Methods") != "PUT, DELETE, OPTIONS" {
+		t.Errorf("OptionsHandler not used: %d %v", w.Code, w.Header())
+	}
+
+	// Errors from OptionsHandler are rendered
+	r.OptionsHandler = func(c Context) error {
+		return ForbiddenError(nil)
+	}
+	if w := serve(r, http.MethodOptions, "/users"); w.Code != http.StatusForbidden {
+		t.Errorf("OptionsHandler error not rendered: %d", w.Code)
+	}
+}
+
+// recordFilter returns a filter which appends name to calls, and returns err
+func recordFilter(calls *[]string, name string, err error) Handler {
+	return func(c Context) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+// TestFilterOrder checks filters run global, group, then route filters, then the handler and after filters
+func TestFilterOrder(t *testing.T) {
+	var calls []string
+	r := newTestRouter(t)
+	r.AddFilter(recordFilter(&calls, "global", nil))
+	r.Group("/admin", func(g *Group) {
+		g.AddFilter(recordFilter(&calls, "g1", nil))
+		g.Group("/users", func(g *Group) {
+			g.AddFilter(recordFilter(&calls, "g2", nil))
+			g.Add("/{id:int}", recordFilter(&calls, "handler", nil)).
+				Filter(recordFilter(&calls, "route", nil)).
+				After(recordFilter(&calls, "after1", nil), recordFilter(&calls, "after2", nil))
+		})
+	})
+
+	w := serve(r, http.MethodGet, "/admin/users/1")
+	want := "global g1 g2 route handler after1 after2"
+	if got := strings.Join(calls, " "); w.Code != http.StatusOK || got != want {
+		t.Errorf("got %d %s want %s", w.Code, got, want)
+	}
+}
+
+// TestFilterErrors checks errors stop the chain and are rendered by ErrorHandler
+func TestFilterErrors(t *testing.T) {
+	var calls []string
+	var rendered []error
+	errStop := NotAuthorizedError(nil)
+
+	tests := []struct {
+		name   string
+		failAt string
+		want   string
+	}{
+		{"no error", "", "global group route handler after"},
+		{"global", "global", "global"},
+		{"group", "group", "global group"},
+		{"route", "route", "global group route"},
+		{"handler", "handler", "global group route handler"},
+		{"after", "after", "global group route handler after"},
+	}
+
+	for _, tt := range tests {
+		calls, rendered = nil, nil
+		filter := func(name string) Handler {
+			if name == tt.failAt {
+				return recordFilter(&calls, name, errStop)
+			}
+			return recordFilter(&calls, name, nil)
+		}
+
+		r := newTestRouter(t)
+		r.ErrorHandler = func(c Context, e error) {
+			rendered = append(rendered, e)
+			c.Writer().WriteHeader(ToStatusError(e).Status)
+		}
+		r.AddFilter(filter("global"))
+		r.Group("/admin", func(g *Group) {
+			g.AddFilter(filter("group"))
+			g.Add("/users", filter("handler")).Filter(filter("route")).After(filter("after"))
+		})
+
+		w := serve(r, http.MethodGet, "/admin/users")
+		if got := strings.Join(calls, " "); got != tt.want {
+			t.Errorf("%s: calls got %s want %s", tt.name, got, tt.want)
+		}
+		if tt.failAt == "" {
+			if w.Code != http.StatusOK || len(rendered) != 0 {
+				t.Errorf("%s: got %d errors %v", tt.name, w.Code, rendered)
+			}
+		} else if w.Code != http.StatusUnauthorized || len(rendered) != 1 || rendered[0] != errStop {
+			t.Errorf("%s: got %d errors %v", tt.name, w.Code, rendered)
+		}
+	}
+}
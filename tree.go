@@ -0,0 +1,206 @@
+package router
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// node is a node in the compressed prefix tree used to find routes for a path.
+// Static nodes match a literal prefix of the path, param nodes match a whole path segment against a regexp.
+// Routes are stored by their index in the router's route table, so that the tree only narrows
+// the routes to check, and insertion order still decides which route wins.
+type node struct {
+	// The literal text matched by this node (static nodes only)
+	prefix string
+
+	// The regexp matched against a path segment (param nodes only)
+	param *regexp.Regexp
+
+	// The source of the param regexp, used to share param nodes between routes
+	paramPattern string
+
+	// Static children, each starting with a different byte
+	children []*node
+
+	// Param children, tried in the order they were added
+	params []*node
+
+	// Routes which match when the path ends exactly at this node
+	exact []int
+
+	// Routes which may match any path reaching this node, these are checked against the route regexp
+	any []int
+}
+
+// insert adds the route stored at index in the route table to the tree below n
+func (n *node) insert(route *Route, index int) {
+	pattern := route.Pattern
+
+	// Plain string routes are stored at the node for their full pattern
+	if route.Regexp == nil {
+		leaf := n.insertStatic(pattern)
+		leaf.exact = append(leaf.exact, index)
+		return
+	}
+
+	idxs, err := route.findBraces(pattern)
+	if err != nil {
+		n.any = append(n.any, index)
+		return
+	}
+
+	current := n
+	end := 0
+	for i := 0; i < len(idxs); i += 2 {
+		literal := pattern[end:idxs[i]]
+		param, err := parseParam(pattern[idxs[i]+1 : idxs[i+1]-1])
+		if err == nil && param.catchAll {
+			// Catch-alls also match the path without a trailing slash
+			current = current.insertStatic(strings.TrimSuffix(literal, "/"))
+			current.any = append(current.any, index)
+			return
+		}
+
+		current = current.insertStatic(literal)
+		if err != nil || !segmentParam(pattern, idxs[i], idxs[i+1], param) {
+			// Leave the rest of the pattern to the route regexp
+			current.any = append(current.any, index)
+			return
+		}
+		current = current.insertParam(param.pattern)
+		end = idxs[i+1]
+	}
+
+	// Route regexps are not anchored at the end, so these routes may match any path with this prefix
+	current = current.insertStatic(pattern[end:])
+	current.any = append(current.any, index)
+}
+
+// insertStatic returns the node matching text below n, adding and splitting nodes as necessary
+func (n *node) insertStatic(text string) *node {
+	for len(text) > 0 {
+		child := n.staticChild(text[0])
+		if child == nil {
+			child = &node{prefix: text}
+			n.children = append(n.children, child)
+			return child
+		}
+
+		// Split the child if it only shares part of its prefix with text
+		l := commonPrefix(text, child.prefix)
+		if l < len(child.prefix) {
+			split := *child
+			split.prefix = child.prefix[l:]
+			*child = node{prefix: child.prefix[:l], children: []*node{&split}}
+		}
+
+		n = child
+		text = text[l:]
+	}
+	return n
+}
+
+// insertParam returns the param child of n matching pattern, adding it if necessary
+func (n *node) insertParam(pattern string) *node {
+	for _, child := range n.params {
+		if child.paramPattern == pattern {
+			return child
+		}
+	}
+	child := &node{
+		param:        regexp.MustCompile("^(?:" + pattern + ")$"),
+		paramPattern: pattern,
+	}
+	n.params = append(n.params, child)
+	return child
+}
+
+// staticChild returns the static child starting with c, or nil if there is none
+func (n *node) staticChild(c byte) *node {
+	for _, child := range n.children {
+		if child.prefix[0] == c {
+			return child
+		}
+	}
+	return nil
+}
+
+// match appends the indexes of all routes which may match path below n to found
+// The indexes are not sorted, and the routes must still be checked against the path
+func (n *node) match(path string, found []int) []int {
+	found = append(found, n.any...)
+	if len(path) == 0 {
+		return append(found, n.exact...)
+	}
+
+	if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.prefix) {
+		found = child.match(path[len(child.prefix):], found)
+	}
+
+	if len(n.params) > 0 {
+		segment := path
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			segment = path[:i]
+		}
+		for _, child := range n.params {
+			if child.param.MatchString(segment) {
+				found = child.match(path[len(segment):], found)
+			}
+		}
+	}
+
+	return found
+}
+
+// segmentParam returns true if the param between start and end in pattern fills a whole path segment and cannot match a slash
+// Only these params can be matched against a single segment without changing which paths a route matches
+func segmentParam(pattern string, start, end int, param routeParam) bool {
+	if start == 0 || pattern[start-1] != '/' || end >= len(pattern) || pattern[end] != '/' {
+		return false
+	}
+
+	re, err := syntax.Parse(param.pattern, syntax.Perl)
+	if err != nil || matchesSlash(re) {
+		return false
+	}
+
+	return true
+}
+
+// matchesSlash returns true if the parsed regexp might match a slash
+func matchesSlash(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '/' {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '/' && '/' <= re.Rune[i+1] {
+				return true
+			}
+		}
+	}
+
+	for _, sub := range re.Sub {
+		if matchesSlash(sub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commonPrefix returns the length of the prefix shared by a and b
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
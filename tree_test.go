@@ -0,0 +1,113 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTreeMatch checks routes are found for tree edge cases
+func TestTreeMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     string
+	}{
+		{"static", []string{"/users", "/users/create"}, "/users/create", "/users/create"},
+		{"static prefix only", []string{"/users/create"}, "/users/creat", ""},
+		{"split node first", []string{"/users", "/uploads"}, "/users", "/users"},
+		{"split node second", []string{"/users", "/uploads"}, "/uploads", "/uploads"},
+		{"split node shared prefix", []string{"/users", "/uploads"}, "/u", ""},
+		{"split node longer first", []string{"/uploads/images", "/uploads"}, "/uploads", "/uploads"},
+		{"final param", []string{"/users/{id:int}"}, "/users/12", "/users/{id:int}"},
+		{"final param unanchored", []string{"/users/{id:int}"}, "/users/12/edit", "/users/{id:int}"},
+		{"final param no match", []string{"/users/{id:int}"}, "/users/abc", ""},
+		{"segment param", []string{"/users/{id:int}/edit"}, "/users/12/edit", "/users/{id:int}/edit"},
+		{"segment param no match", []string{"/users/{id:int}/edit"}, "/users/ab/edit", ""},
+		{"segment params shared", []string{"/users/{id:int}/edit", "/users/{id:int}/show"}, "/users/12/show", "/users/{id:int}/show"},
+		{"param not whole segment", []string{"/users/{id:int}-{slug:slug}"}, "/users/12-hello", "/users/{id:int}-{slug:slug}"},
+		{"param after literal", []string{"/a{x:[a-z]}"}, "/ab", "/a{x:[a-z]}"},
+		{"param matching slash", []string{"/files/{id:[^x]+}/y"}, "/files/a/b/y", "/files/{id:[^x]+}/y"},
+		{"catch-all", []string{"/docs/{path...}"}, "/docs/a/b", "/docs/{path...}"},
+		{"catch-all empty", []string{"/docs/{path...}"}, "/docs/", "/docs/{path...}"},
+		{"catch-all no slash", []string{"/docs/{path...}"}, "/docs", "/docs/{path...}"},
+		{"catch-all other prefix", []string{"/docs/{path...}"}, "/docsx", ""},
+		{"order static first", []string{"/users/create", "/users/{name}"}, "/users/create", "/users/create"},
+		{"order param first", []string{"/users/{name}", "/users/create"}, "/users/create", "/users/{name}"},
+		{"order catch-all first", []string{"/{all:.*}", "/users"}, "/users", "/{all:.*}"},
+	}
+
+	for _, tt := range tests {
+		r := newTestRouter(t)
+		for _, p := range tt.patterns {
+			r.Add(p, testHandler)
+		}
+
+		request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		route, _ := r.findRoute(tt.path, request)
+		got := ""
+		if route != nil {
+			got = route.Pattern
+		}
+		if got != tt.want {
+			t.Errorf("%s: path %s got %q want %q", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestTreeInsert checks where routes are stored in the tree
+func TestTreeInsert(t *testing.T) {
+	r := newTestRouter(t)
+	r.Add("/users", testHandler)                 // 0
+	r.Add("/uploads", testHandler)               // 1
+	r.Add("/users/{id:int}", testHandler)        // 2
+	r.Add("/users/{id:int}/edit", testHandler)   // 3
+	r.Add("/users/{id:int}-{slug}", testHandler) // 4
+	r.Add("/docs/{path...}", testHandler)        // 5
+
+	// Adding /uploads splits /users into /u with children sers and ploads
+	u := r.tree.staticChild('/').staticChild('u')
+	if u == nil || u.prefix != "u" || len(u.children) != 2 {
+		t.Fatalf("node not split: %+v", u)
+	}
+	users := u.staticChild('s')
+	if users.prefix != "sers" || !equalInts(users.exact, []int{0}) {
+		t.Errorf("users node wrong: %+v", users)
+	}
+	if uploads := u.staticChild('p'); uploads.prefix != "ploads" || !equalInts(uploads.exact, []int{1}) {
+		t.Errorf("uploads node wrong: %+v", uploads)
+	}
+
+	// Final params and params which are not whole segments are left to the route regexp
+	slash := users.staticChild('/')
+	if slash == nil || !equalInts(slash.any, []int{2, 4}) {
+		t.Errorf("final params not stored in any: %+v", slash)
+	}
+
+	// Params followed by a slash are param nodes
+	if len(slash.params) != 1 || slash.params[0].paramPattern != "-?[0-9]+" {
+		t.Fatalf("segment param not stored: %+v", slash.params)
+	}
+	if edit := slash.params[0].staticChild('/'); edit == nil || edit.prefix != "/edit" || !equalInts(edit.any, []int{3}) {
+		t.Errorf("edit node wrong: %+v", edit)
+	}
+
+	// Catch-alls are stored at the node without the trailing slash
+	if docs := r.tree.staticChild('/').staticChild('d'); docs == nil || docs.prefix != "docs" || !equalInts(docs.any, []int{5}) {
+		t.Errorf("catch-all node wrong: %+v", docs)
+	}
+}
+
+// equalInts returns true if a and b hold the same ints in the same order
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// recordingWriter wraps a http.ResponseWriter and records the status, bytes written and time to first byte
+type recordingWriter struct {
+	http.ResponseWriter
+
+	// The time the request started, used to measure time to first byte
+	started time.Time
+
+	// The status written, 0 until the header is written
+	status int
+
+	// The number of bytes of body written
+	bytes int64
+
+	// The time from started until the header or body was first written
+	firstByte time.Duration
+}
+
+// newRecordingWriter returns a recordingWriter wrapping writer, unless writer already is one
+func newRecordingWriter(writer http.ResponseWriter, started time.Time) *recordingWriter {
+	if w, ok := writer.(*recordingWriter); ok {
+		return w
+	}
+	return &recordingWriter{ResponseWriter: writer, started: started}
+}
+
+// WriteHeader records the status and sends an HTTP response header with status code
+// Informational statuses like 103 Early Hints are sent before the final status, so they are not recorded
+func (w *recordingWriter) WriteHeader(status int) {
+	informational := status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+	if w.status == 0 && !informational {
+		w.status = status
+		w.firstByte = time.Since(w.started)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the bytes written and writes the data to the connection as part of an HTTP reply
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.firstByte = time.Since(w.started)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom records the bytes written and copies the data from src to the connection
+// The wrapped writer's ReadFrom is used if it has one, so that files may be sent with sendfile
+func (w *recordingWriter) ReadFrom(src io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		// Hide our ReadFrom from io.Copy, Write records the bytes
+		return io.Copy(struct{ io.Writer }{w}, src)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.firstByte = time.Since(w.started)
+	}
+	n, err := rf.ReadFrom(src)
+	w.bytes += n
+	return n, err
+}
+
+// Status returns the status written, or 200 if no status has been written yet
+func (w *recordingWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Flush sends any buffered data to the client, if the wrapped writer supports it
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+			w.firstByte = time.Since(w.started)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, if the wrapped writer supports it
+func (w *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("Response writer does not support hijacking")
+}
+
+// Unwrap returns the wrapped writer, for use by http.ResponseController
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readerFromRecorder is a ResponseRecorder which records calls to ReadFrom
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+// TestRecordingWriterInformational checks informational statuses are not recorded as the final status
+func TestRecordingWriterInformational(t *testing.T) {
+	w := newRecordingWriter(httptest.NewRecorder(), time.Now())
+	w.Header().Set("Link", "</style.css>; rel=preload")
+	w.WriteHeader(http.StatusEarlyHints)
+	if w.status != 0 {
+		t.Errorf("103 recorded as status")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if w.Status() != http.StatusCreated {
+		t.Errorf("status got %d want %d", w.Status(), http.StatusCreated)
+	}
+
+	w = newRecordingWriter(httptest.NewRecorder(), time.Now())
+	w.WriteHeader(http.StatusSwitchingProtocols)
+	if w.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("101 not recorded as status")
+	}
+}
+
+// TestRecordingWriterReadFrom checks ReadFrom uses the wrapped writer's ReadFrom and records the bytes
+func TestRecordingWriterReadFrom(t *testing.T) {
+	body := strings.Repeat("a", 100)
+
+	rf := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := newRecordingWriter(rf, time.Now())
+	n, err := io.Copy(w, struct{ io.Reader }{strings.NewReader(body)})
+	if err != nil || n != 100 || !rf.readFrom || w.bytes != 100 || w.Status() != http.StatusOK || rf.Body.String() != body {
+		t.Errorf("ReadFrom not forwarded: n %d err %v readFrom %t bytes %d", n, err, rf.readFrom, w.bytes)
+	}
+
+	// Writers without ReadFrom are written to with Write
+	buf := &bytes.Buffer{}
+	w = newRecordingWriter(&writerOnly{httptest.NewRecorder(), buf}, time.Now())
+	n, err = w.ReadFrom(strings.NewReader(body))
+	if err != nil || n != 100 || w.bytes != 100 || buf.String() != body {
+		t.Errorf("ReadFrom failed: n %d err %v bytes %d", n, err, w.bytes)
+	}
+}
+
+// writerOnly is a ResponseWriter without ReadFrom, writing the body to buf
+type writerOnly struct {
+	*httptest.ResponseRecorder
+	buf *bytes.Buffer
+}
+
+func (w *writerOnly) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}